@@ -3,6 +3,8 @@ package action
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
 	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
@@ -26,7 +28,7 @@ func (a AttachDisk) Run(vmCID VMCID, diskCID DiskCID) (interface{}, error) {
 	}
 
 	if !found {
-		return nil, bosherr.Errorf("Expected to find VM '%s'", vmCID)
+		return nil, bslcapi.NewVMNotFoundError(vmCID.String())
 	}
 
 	disk, found, err := a.diskFinder.Find(int(diskCID))
@@ -35,7 +37,7 @@ func (a AttachDisk) Run(vmCID VMCID, diskCID DiskCID) (interface{}, error) {
 	}
 
 	if !found {
-		return nil, bosherr.Errorf("Expected to find disk '%s'", diskCID)
+		return nil, bslcapi.NewDiskNotFoundError(diskCID.String())
 	}
 
 	err = vm.AttachDisk(disk)
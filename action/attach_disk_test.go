@@ -8,6 +8,8 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
 	fakedisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk/fakes"
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
 )
@@ -92,7 +94,8 @@ var _ = Describe("AttachDisk", func() {
 
 					_, err := action.Run(1234, 1234)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("Expected to find disk"))
+					Expect(err.Error()).To(Equal("Disk '1234' not found"))
+					Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::DiskNotFound"))
 				})
 			})
 
@@ -113,7 +116,8 @@ var _ = Describe("AttachDisk", func() {
 
 				_, err := action.Run(1234, 1234)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("Expected to find VM"))
+				Expect(err.Error()).To(Equal("VM '1234' not found"))
+				Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::VMNotFound"))
 			})
 		})
 
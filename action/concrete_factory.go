@@ -8,8 +8,10 @@ import (
 
 	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
 	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
 )
 
 type concreteFactory struct {
@@ -17,20 +19,41 @@ type concreteFactory struct {
 }
 
 func NewConcreteFactory(softLayerClient sl.Client, options ConcreteFactoryOptions, logger boshlog.Logger) concreteFactory {
-	stemcellFinder := bslcstem.NewSoftLayerFinder(softLayerClient, logger)
+	waitOptions := options.Wait.WithDefaults()
 
-	agentEnvServiceFactory := bslcvm.NewSoftLayerAgentEnvServiceFactory(softLayerClient, logger)
+	stemcellRegistry := bslcstem.NewFileRegistry(options.StemcellsDir, logger)
+	stemcellFinder := bslcstem.NewRegistryFinder(
+		bslcstem.NewSoftLayerFinder(softLayerClient, logger),
+		stemcellRegistry,
+		options.StemcellCache.WithDefaults(bslcstem.DefaultCacheOptions),
+		softLayerClient,
+		logger,
+	)
+
+	stemcellCreator := bslcstem.NewSoftLayerCreator(
+		softLayerClient,
+		bslcstem.NewSwiftUploader(options.ObjectStorage, logger),
+		waitOptions.Create,
+		logger,
+	)
+
+	agentEnvServiceFactory := bslcvm.NewSoftLayerAgentEnvServiceFactory(softLayerClient, waitOptions.Metadata, logger)
 
 	vmCreator := bslcvm.NewSoftLayerCreator(
 		softLayerClient,
 		agentEnvServiceFactory,
 		options.Agent,
+		waitOptions,
+		options.KeepFailedVMs,
+		options.ReplicateStemcells,
 		logger,
 	)
 
 	vmFinder := bslcvm.NewSoftLayerFinder(
 		softLayerClient,
 		agentEnvServiceFactory,
+		waitOptions,
+		options.ScanAccountForVMs,
 		logger,
 	)
 
@@ -47,14 +70,24 @@ func NewConcreteFactory(softLayerClient sl.Client, options ConcreteFactoryOption
 		logger,
 	)
 
+	snapshotCreator := bslcsnap.NewSoftLayerSnapshotCreator(
+		softLayerClient,
+		logger,
+	)
+
+	snapshotFinder := bslcsnap.NewSoftLayerSnapshotFinder(
+		softLayerClient,
+		logger,
+	)
+
 	return concreteFactory{
 		availableActions: map[string]Action{
 			// Stemcell management
-			"create_stemcell": NewCreateStemcell(stemcellFinder),
-			"delete_stemcell": NewDeleteStemcell(stemcellFinder),
+			"create_stemcell": NewCreateStemcell(stemcellFinder, stemcellCreator, stemcellRegistry, logger),
+			"delete_stemcell": NewDeleteStemcell(stemcellFinder, stemcellRegistry, options.KeepSharedImages, logger),
 
 			// VM management
-			"create_vm":          NewCreateVM(stemcellFinder, vmCreator),
+			"create_vm":          NewCreateVM(stemcellFinder, vmCreator, options.VMDefaults, options.VMProfiles, logger),
 			"delete_vm":          NewDeleteVM(vmFinder),
 			"has_vm":             NewHasVM(vmFinder),
 			"reboot_vm":          NewRebootVM(vmFinder),
@@ -64,19 +97,19 @@ func NewConcreteFactory(softLayerClient sl.Client, options ConcreteFactoryOption
 			// Disk management
 			"create_disk": NewCreateDisk(diskCreator),
 			"delete_disk": NewDeleteDisk(diskFinder),
-			"attach_disk": NewAttachDisk(vmFinder, nil),
-			"detach_disk": NewDetachDisk(vmFinder, nil),
+			"attach_disk": NewAttachDisk(vmFinder, diskFinder),
+			"detach_disk": NewDetachDisk(vmFinder, diskFinder),
+			"get_disks":   NewGetDisks(vmFinder),
 
-			"establish_bare_metal_env": NewEstablishBareMetalEnv(bmCreator, bmFinder),
+			// Snapshot management
+			"snapshot_disk":   NewSnapshotDisk(diskFinder, snapshotCreator),
+			"delete_snapshot": NewDeleteSnapshot(snapshotFinder),
 
-			// Not implemented (disk related):
-			//   snapshot_disk
-			//   delete_snapshot
-			//   get_disks
+			"establish_bare_metal_env": NewEstablishBareMetalEnv(bmCreator, bmFinder, waitOptions.BareMetal),
 
-			// Not implemented (others):
-			//   current_vm_id
-			//   ping
+			// Others
+			"ping":          NewPing(softLayerClient),
+			"current_vm_id": NewCurrentVMID(vmFinder, util.InterfaceIPResolver{}),
 		},
 	}
 }
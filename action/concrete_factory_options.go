@@ -3,13 +3,49 @@ package action
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
 
 type ConcreteFactoryOptions struct {
 	StemcellsDir string
 
+	// How long create_vm trusts the stemcells recorded under StemcellsDir
+	// before looking them up on SoftLayer again, a day when not set
+	StemcellCache bslcstem.CacheOptions
+
+	// Leave the images of VirtualDiskImage stemcells, which may be shared
+	// with other deployments, in place when their stemcell is deleted
+	KeepSharedImages bool
+
 	Agent bslcvm.AgentOptions
+
+	// Only needed to create stemcells from their root image
+	ObjectStorage bslcstem.ObjectStorageOptions
+
+	// How long to wait on SoftLayer for each kind of operation; anything
+	// left unset uses the defaults in softlayer/common
+	Wait bslcommon.OperationWaitOptions
+
+	// Used for any create_vm cloud property the manifest leaves unset
+	VMDefaults bslcvm.VMCloudProperties
+
+	// Named cloud properties selected with the 'profile' cloud property,
+	// used before VMDefaults
+	VMProfiles map[string]bslcvm.VMCloudProperties
+
+	// Leave virtual guests that fail after being ordered running for
+	// debugging instead of cancelling them
+	KeepFailedVMs bool
+
+	// Copy stemcell templates to the datacenter of a VM when they are not
+	// available there, instead of failing create_vm
+	ReplicateStemcells bool
+
+	// Find VMs by listing every virtual guest of the account instead of
+	// looking each one up by ID; slow on accounts with many guests
+	ScanAccountForVMs bool
 }
 
 func (o ConcreteFactoryOptions) Validate() error {
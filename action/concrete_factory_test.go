@@ -13,9 +13,12 @@ import (
 
 	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
 
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
 )
 
 var _ = Describe("concreteFactory", func() {
@@ -34,10 +37,14 @@ var _ = Describe("concreteFactory", func() {
 	)
 
 	var (
+		waitOptions bslcommon.OperationWaitOptions
+
 		agentEnvServiceFactory bslcvm.AgentEnvServiceFactory
 
-		stemcellFinder bslcstem.Finder
-		vmFinder       bslcvm.Finder
+		stemcellRegistry bslcstem.Registry
+		stemcellFinder   bslcstem.Finder
+		vmFinder         bslcvm.Finder
+		diskFinder       bslcdisk.Finder
 	)
 
 	BeforeEach(func() {
@@ -45,6 +52,7 @@ var _ = Describe("concreteFactory", func() {
 		fs = fakesys.NewFakeFileSystem()
 		cmdRunner = fakesys.NewFakeCmdRunner()
 		compressor = fakecmd.NewFakeCompressor()
+		_, _, _ = fs, cmdRunner, compressor
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
 		factory = NewConcreteFactory(
@@ -55,13 +63,29 @@ var _ = Describe("concreteFactory", func() {
 	})
 
 	BeforeEach(func() {
-		agentEnvServiceFactory = bslcvm.NewSoftLayerAgentEnvServiceFactory(softLayerClient, logger)
+		waitOptions = bslcommon.OperationWaitOptions{}.WithDefaults()
 
-		stemcellFinder = bslcstem.NewSoftLayerFinder(softLayerClient, logger)
+		agentEnvServiceFactory = bslcvm.NewSoftLayerAgentEnvServiceFactory(softLayerClient, waitOptions.Metadata, logger)
+
+		stemcellRegistry = bslcstem.NewFileRegistry(options.StemcellsDir, logger)
+		stemcellFinder = bslcstem.NewRegistryFinder(
+			bslcstem.NewSoftLayerFinder(softLayerClient, logger),
+			stemcellRegistry,
+			options.StemcellCache.WithDefaults(bslcstem.DefaultCacheOptions),
+			softLayerClient,
+			logger,
+		)
 
 		vmFinder = bslcvm.NewSoftLayerFinder(
 			softLayerClient,
 			agentEnvServiceFactory,
+			waitOptions,
+			options.ScanAccountForVMs,
+			logger,
+		)
+
+		diskFinder = bslcdisk.NewSoftLayerDiskFinder(
+			softLayerClient,
 			logger,
 		)
 	})
@@ -70,13 +94,20 @@ var _ = Describe("concreteFactory", func() {
 		It("create_stemcell", func() {
 			action, err := factory.Create("create_stemcell")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(action).To(Equal(NewCreateStemcell(stemcellFinder)))
+			stemcellCreator := bslcstem.NewSoftLayerCreator(
+				softLayerClient,
+				bslcstem.NewSwiftUploader(options.ObjectStorage, logger),
+				waitOptions.Create,
+				logger,
+			)
+
+			Expect(action).To(Equal(NewCreateStemcell(stemcellFinder, stemcellCreator, stemcellRegistry, logger)))
 		})
 
 		It("delete_stemcell", func() {
 			action, err := factory.Create("delete_stemcell")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(action).To(Equal(NewDeleteStemcell(stemcellFinder)))
+			Expect(action).To(Equal(NewDeleteStemcell(stemcellFinder, stemcellRegistry, options.KeepSharedImages, logger)))
 		})
 	})
 
@@ -86,12 +117,15 @@ var _ = Describe("concreteFactory", func() {
 				softLayerClient,
 				agentEnvServiceFactory,
 				options.Agent,
+				waitOptions,
+				options.KeepFailedVMs,
+				options.ReplicateStemcells,
 				logger,
 			)
 
 			action, err := factory.Create("create_vm")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(action).To(Equal(NewCreateVM(stemcellFinder, vmCreator)))
+			Expect(action).To(Equal(NewCreateVM(stemcellFinder, vmCreator, options.VMDefaults, options.VMProfiles, logger)))
 		})
 
 		It("delete_vm", func() {
@@ -138,58 +172,65 @@ var _ = Describe("concreteFactory", func() {
 		})
 
 		It("delete_disk", func() {
-			diskFinder := bslcdisk.NewSoftLayerDiskFinder(
-				softLayerClient,
-				logger,
-			)
-
 			action, err := factory.Create("delete_disk")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(action).To(Equal(NewDeleteDisk(diskFinder)))
 		})
 
-		XIt("attach_disk", func() {
+		It("attach_disk", func() {
 			action, err := factory.Create("attach_disk")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(action).To(BeNil())
+			Expect(action).To(Equal(NewAttachDisk(vmFinder, diskFinder)))
 		})
 
-		XIt("detach_disk", func() {
+		It("detach_disk", func() {
 			action, err := factory.Create("detach_disk")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(action).To(BeNil())
+			Expect(action).To(Equal(NewDetachDisk(vmFinder, diskFinder)))
 		})
-	})
 
-	Context("Unsupported methods", func() {
-		It("returns error because CPI machine is not self-aware if action is current_vm_id", func() {
-			action, err := factory.Create("current_vm_id")
-			Expect(err).To(HaveOccurred())
-			Expect(action).To(BeNil())
+		It("get_disks", func() {
+			action, err := factory.Create("get_disks")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal(NewGetDisks(vmFinder)))
 		})
+	})
+
+	Context("Snapshot methods", func() {
+		It("snapshot_disk", func() {
+			snapshotCreator := bslcsnap.NewSoftLayerSnapshotCreator(
+				softLayerClient,
+				logger,
+			)
 
-		It("returns error because snapshotting is not implemented if action is snapshot_disk", func() {
 			action, err := factory.Create("snapshot_disk")
-			Expect(err).To(HaveOccurred())
-			Expect(action).To(BeNil())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal(NewSnapshotDisk(diskFinder, snapshotCreator)))
 		})
 
-		It("returns error because snapshotting is not implemented if action is delete_snapshot", func() {
+		It("delete_snapshot", func() {
+			snapshotFinder := bslcsnap.NewSoftLayerSnapshotFinder(
+				softLayerClient,
+				logger,
+			)
+
 			action, err := factory.Create("delete_snapshot")
-			Expect(err).To(HaveOccurred())
-			Expect(action).To(BeNil())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal(NewDeleteSnapshot(snapshotFinder)))
 		})
+	})
 
-		It("returns error since CPI should not keep state if action is get_disks", func() {
-			action, err := factory.Create("get_disks")
-			Expect(err).To(HaveOccurred())
-			Expect(action).To(BeNil())
+	Context("Other methods", func() {
+		It("ping", func() {
+			action, err := factory.Create("ping")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal(NewPing(softLayerClient)))
 		})
 
-		It("returns error because ping is not official CPI method if action is ping", func() {
-			action, err := factory.Create("ping")
-			Expect(err).To(HaveOccurred())
-			Expect(action).To(BeNil())
+		It("current_vm_id", func() {
+			action, err := factory.Create("current_vm_id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(action).To(Equal(NewCurrentVMID(vmFinder, util.InterfaceIPResolver{})))
 		})
 	})
 
@@ -3,6 +3,8 @@ package action
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
 
@@ -16,15 +18,19 @@ func NewConfigureNetworks(vmFinder bslcvm.Finder) ConfigureNetworks {
 	}
 }
 
-func (a ConfigureNetworks) Run(vmCID VMCID, networks bslcvm.Networks) (interface{}, error) {
+func (a ConfigureNetworks) Run(vmCID VMCID, networks Networks) (interface{}, error) {
 	vm, found, err := a.vmFinder.Find(int(vmCID))
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Finding vm '%s'", vmCID)
 	}
 
 	if found {
-		err := vm.ConfigureNetworks(networks)
+		err := vm.ConfigureNetworks(networks.AsVMNetworks())
 		if err != nil {
+			if _, ok := err.(bslcvm.NotSupportedError); ok {
+				return nil, bslcapi.NotSupportedError{}
+			}
+
 			return nil, bosherr.WrapErrorf(err, "Configuring networks vm '%s'", vmCID)
 		}
 	}
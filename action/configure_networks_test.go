@@ -8,6 +8,7 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
@@ -17,13 +18,18 @@ var _ = Describe("ConfigureNetworks", func() {
 	var (
 		vmFinder *fakevm.FakeFinder
 		action   ConfigureNetworks
-		networks bslcvm.Networks
+		networks Networks
 	)
 
 	BeforeEach(func() {
 		vmFinder = &fakevm.FakeFinder{}
 		action = NewConfigureNetworks(vmFinder)
-		networks = bslcvm.Networks{}
+		networks = Networks{
+			"fake-net-name": Network{
+				Type:            "dynamic",
+				CloudProperties: map[string]interface{}{"vlanId": 524954},
+			},
+		}
 	})
 
 	Describe("Run", func() {
@@ -50,7 +56,7 @@ var _ = Describe("ConfigureNetworks", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(vm.ConfigureNetworksCalled).To(BeTrue())
-				Expect(vm.Networks).To(Equal(networks))
+				Expect(vm.Networks).To(Equal(networks.AsVMNetworks()))
 			})
 
 			It("returns error if configure networks fails", func() {
@@ -60,6 +66,14 @@ var _ = Describe("ConfigureNetworks", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-configure-networks-err"))
 			})
+
+			It("returns NotSupportedError if the networks cannot be configured in place", func() {
+				vm.ConfigureNetworksErr = bslcvm.NotSupportedError{}
+
+				_, err := action.Run(1234, networks)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(bslcapi.NotSupportedError{}))
+			})
 		})
 
 		Context("when vm is not found with given cid", func() {
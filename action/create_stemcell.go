@@ -2,25 +2,45 @@ package action
 
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 )
 
+const createStemcellLogTag = "CreateStemcell"
+
 type CreateStemcell struct {
-	stemcellFinder bslcstem.Finder
+	stemcellFinder   bslcstem.Finder
+	stemcellCreator  bslcstem.Creator
+	stemcellRegistry bslcstem.Registry
+
+	logger boshlog.Logger
 }
 
 type CreateStemcellCloudProps struct {
 	Id             int    `json:"virtual-disk-image-id"`
 	Uuid           string `json:"virtual-disk-image-uuid"`
 	DatacenterName string `json:"datacenter-name"`
+
+	Name                         string `json:"name"`
+	Version                      string `json:"version"`
+	OperatingSystemReferenceCode string `json:"operating-system-reference-code"`
 }
 
-func NewCreateStemcell(stemcellFinder bslcstem.Finder) CreateStemcell {
-	return CreateStemcell{stemcellFinder: stemcellFinder}
+func NewCreateStemcell(stemcellFinder bslcstem.Finder, stemcellCreator bslcstem.Creator, stemcellRegistry bslcstem.Registry, logger boshlog.Logger) CreateStemcell {
+	return CreateStemcell{
+		stemcellFinder:   stemcellFinder,
+		stemcellCreator:  stemcellCreator,
+		stemcellRegistry: stemcellRegistry,
+		logger:           logger,
+	}
 }
 
 func (a CreateStemcell) Run(imagePath string, stemcellCloudProps CreateStemcellCloudProps) (StemcellCID, error) {
+	if stemcellCloudProps.Id == 0 {
+		return a.createFromImage(imagePath, stemcellCloudProps)
+	}
+
 	stemcell, found, err := a.stemcellFinder.FindById(stemcellCloudProps.Id)
 	if err != nil {
 		return 0, bosherr.WrapErrorf(err, "Finding stemcell with ID '%d'", stemcellCloudProps.Id)
@@ -30,5 +50,48 @@ func (a CreateStemcell) Run(imagePath string, stemcellCloudProps CreateStemcellC
 		return 0, bosherr.Errorf("Did not find stemcell with ID '%d'", stemcellCloudProps.Id)
 	}
 
+	a.register(stemcell, stemcellCloudProps)
+
 	return StemcellCID(stemcell.ID()), nil
 }
+
+func (a CreateStemcell) createFromImage(imagePath string, stemcellCloudProps CreateStemcellCloudProps) (StemcellCID, error) {
+	metadata := bslcstem.ImageMetadata{
+		Name:    stemcellCloudProps.Name,
+		Version: stemcellCloudProps.Version,
+
+		OperatingSystemReferenceCode: stemcellCloudProps.OperatingSystemReferenceCode,
+	}
+
+	stemcell, err := a.stemcellCreator.Create(imagePath, metadata)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Creating stemcell from image '%s'", imagePath)
+	}
+
+	a.register(stemcell, stemcellCloudProps)
+
+	return StemcellCID(stemcell.ID()), nil
+}
+
+// register only logs failures since stemcells missing from the registry are
+// still found on SoftLayer
+func (a CreateStemcell) register(stemcell bslcstem.Stemcell, stemcellCloudProps CreateStemcellCloudProps) {
+	record := bslcstem.RegistryRecord{
+		Kind: stemcell.Kind(),
+		Uuid: stemcell.Uuid(),
+
+		Name:    stemcellCloudProps.Name,
+		Version: stemcellCloudProps.Version,
+	}
+
+	datacenters, err := stemcell.Datacenters()
+	if err != nil {
+		a.logger.Warn(createStemcellLogTag, "Getting datacenters of stemcell '%d': %s", stemcell.ID(), err)
+	}
+	record.Datacenters = datacenters
+
+	err = a.stemcellRegistry.Save(stemcell.ID(), record)
+	if err != nil {
+		a.logger.Warn(createStemcellLogTag, "Recording stemcell '%d' in registry: %s", stemcell.ID(), err)
+	}
+}
@@ -8,37 +8,139 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
 	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
 )
 
 var _ = Describe("CreateStemcell", func() {
 	var (
-		stemcellFinder *fakestem.FakeFinder
-		action         CreateStemcell
+		stemcellFinder   *fakestem.FakeFinder
+		stemcellCreator  *fakestem.FakeCreator
+		stemcellRegistry *fakestem.FakeRegistry
+		action           CreateStemcell
 	)
 
 	BeforeEach(func() {
 		stemcellFinder = &fakestem.FakeFinder{}
-		action = NewCreateStemcell(stemcellFinder)
+		stemcellCreator = &fakestem.FakeCreator{}
+		stemcellRegistry = fakestem.NewFakeRegistry()
+		action = NewCreateStemcell(stemcellFinder, stemcellCreator, stemcellRegistry, boshlog.NewLogger(boshlog.LevelNone))
 	})
 
 	Describe("Run", func() {
-		It("returns id for created stemcell from image path", func() {
-			stemcellFinder.FindFound, stemcellFinder.FindErr = true, nil
-			stemcellFinder.FindStemcell = fakestem.NewFakeStemcell(1234, "fake-stemcell-id", fakestem.FakeStemcellKind)
+		Context("when virtual disk image ID is given", func() {
+			It("returns id for existing stemcell", func() {
+				stemcellFinder.FindFound, stemcellFinder.FindErr = true, nil
+				stemcellFinder.FindStemcell = fakestem.NewFakeStemcell(1234, "fake-stemcell-id", fakestem.FakeStemcellKind)
+
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234, Uuid: "fake-stemcell-id"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id).To(Equal(StemcellCID(1234)))
+
+				Expect(stemcellFinder.FindID).To(Equal(1234))
+				Expect(stemcellCreator.CreateImagePath).To(BeEmpty())
+			})
+
+			It("records the existing stemcell in the registry", func() {
+				stemcell := fakestem.NewFakeStemcell(1234, "fake-stemcell-id", bslcstem.VirtualDiskImageKind)
+				stemcell.DatacentersResult = []string{"ams01", "dal05"}
+				stemcellFinder.FindFound, stemcellFinder.FindErr = true, nil
+				stemcellFinder.FindStemcell = stemcell
+
+				_, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234, DatacenterName: "fake-datacenter", Name: "fake-name", Version: "fake-version"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stemcellRegistry.Records).To(Equal(map[int]bslcstem.RegistryRecord{
+					1234: bslcstem.RegistryRecord{
+						Kind:        bslcstem.VirtualDiskImageKind,
+						Uuid:        "fake-stemcell-id",
+						Datacenters: []string{"ams01", "dal05"},
+						Name:        "fake-name",
+						Version:     "fake-version",
+					},
+				}))
+			})
+
+			It("records the stemcell without datacenters when they cannot be found", func() {
+				stemcell := fakestem.NewFakeStemcell(1234, "fake-stemcell-id", bslcstem.VirtualDiskImageKind)
+				stemcell.DatacentersErr = errors.New("fake-datacenters-err")
+				stemcellFinder.FindFound, stemcellFinder.FindErr = true, nil
+				stemcellFinder.FindStemcell = stemcell
+
+				_, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stemcellRegistry.Records).To(HaveKey(1234))
+				Expect(stemcellRegistry.Records[1234].Datacenters).To(BeEmpty())
+			})
 
-			id, err := action.Run("fake-path", CreateStemcellCloudProps{Uuid: "fake-stemcell-id"})
-			Expect(err).ToNot(HaveOccurred())
-			Expect(id).To(Equal(StemcellCID(1234)))
+			It("does not fail when recording the stemcell fails", func() {
+				stemcellFinder.FindFound, stemcellFinder.FindErr = true, nil
+				stemcellFinder.FindStemcell = fakestem.NewFakeStemcell(1234, "fake-stemcell-id", bslcstem.VirtualDiskImageKind)
+				stemcellRegistry.SaveErr = errors.New("fake-save-err")
+
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id).To(Equal(StemcellCID(1234)))
+			})
+
+			It("returns error if finding stemcell fails", func() {
+				stemcellFinder.FindFound, stemcellFinder.FindErr = false, errors.New("fake-add-err")
+
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234, Uuid: "fake-stemcell-id"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-add-err"))
+				Expect(id).To(Equal(StemcellCID(0)))
+			})
+
+			It("returns error if stemcell is not found", func() {
+				stemcellFinder.FindFound = false
+
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{Id: 1234})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Did not find stemcell with ID '1234'"))
+				Expect(id).To(Equal(StemcellCID(0)))
+			})
 		})
 
-		It("returns error if creating stemcell fails", func() {
-			stemcellFinder.FindFound, stemcellFinder.FindErr = false, errors.New("fake-add-err")
+		Context("when virtual disk image ID is not given", func() {
+			It("returns id for stemcell created from image path", func() {
+				stemcellCreator.CreateStemcell = fakestem.NewFakeStemcell(5678, "fake-stemcell-uuid", bslcstem.VirtualGuestDeviceTemplateGroupKind)
+
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{
+					Name:    "fake-name",
+					Version: "fake-version",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id).To(Equal(StemcellCID(5678)))
+
+				Expect(stemcellCreator.CreateImagePath).To(Equal("fake-path"))
+				Expect(stemcellCreator.CreateMetadata).To(Equal(bslcstem.ImageMetadata{
+					Name:    "fake-name",
+					Version: "fake-version",
+				}))
+
+				Expect(stemcellRegistry.Records).To(Equal(map[int]bslcstem.RegistryRecord{
+					5678: bslcstem.RegistryRecord{
+						Kind:    bslcstem.VirtualGuestDeviceTemplateGroupKind,
+						Uuid:    "fake-stemcell-uuid",
+						Name:    "fake-name",
+						Version: "fake-version",
+					},
+				}))
+			})
+
+			It("returns error if creating stemcell fails", func() {
+				stemcellCreator.CreateErr = errors.New("fake-create-err")
 
-			id, err := action.Run("fake-path", CreateStemcellCloudProps{Uuid: "fake-stemcell-id"})
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("fake-add-err"))
-			Expect(id).To(Equal(StemcellCID(0)))
+				id, err := action.Run("fake-path", CreateStemcellCloudProps{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+				Expect(id).To(Equal(StemcellCID(0)))
+			})
 		})
 	})
 })
@@ -2,29 +2,46 @@ package action
 
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
 
+const createVMLogTag = "CreateVM"
+
 type CreateVM struct {
-	stemcellFinder    bslcstem.Finder
-	vmCreator         bslcvm.Creator
-	vmCloudProperties bslcvm.VMCloudProperties
+	stemcellFinder bslcstem.Finder
+	vmCreator      bslcvm.Creator
+
+	vmDefaults bslcvm.VMCloudProperties
+	vmProfiles map[string]bslcvm.VMCloudProperties
+
+	logger boshlog.Logger
 }
 
 type Environment map[string]interface{}
 
-func NewCreateVM(stemcellFinder bslcstem.Finder, vmCreator bslcvm.Creator) CreateVM {
+func NewCreateVM(stemcellFinder bslcstem.Finder, vmCreator bslcvm.Creator, vmDefaults bslcvm.VMCloudProperties, vmProfiles map[string]bslcvm.VMCloudProperties, logger boshlog.Logger) CreateVM {
 	return CreateVM{
-		stemcellFinder:    stemcellFinder,
-		vmCreator:         vmCreator,
-		vmCloudProperties: bslcvm.VMCloudProperties{},
+		stemcellFinder: stemcellFinder,
+		vmCreator:      vmCreator,
+		vmDefaults:     vmDefaults,
+		vmProfiles:     vmProfiles,
+		logger:         logger,
 	}
 }
 
 func (a CreateVM) Run(agentID string, stemcellCID StemcellCID, cloudProps bslcvm.VMCloudProperties, networks Networks, diskIDs []DiskCID, env Environment) (VMCID, error) {
-	a.updateCloudProperties(cloudProps)
+	cloudProps, err := a.mergeCloudProperties(cloudProps)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Merging cloud properties")
+	}
+
+	a.logger.Debug(createVMLogTag, "Creating VM with merged cloud properties %#v", cloudProps)
 
 	stemcell, found, err := a.stemcellFinder.FindById(int(stemcellCID))
 	if err != nil {
@@ -41,26 +58,42 @@ func (a CreateVM) Run(agentID string, stemcellCID StemcellCID, cloudProps bslcvm
 
 	vm, err := a.vmCreator.Create(agentID, stemcell, cloudProps, vmNetworks, vmEnv)
 	if err != nil {
+		if _, ok := err.(bslcommon.InvalidCloudPropertiesError); ok {
+			return 0, bslcapi.NewVMCreationFailedError(err.Error(), false)
+		}
+
+		if _, ok := err.(bslcvm.StemcellNotInDatacenterError); ok {
+			return 0, bslcapi.NewVMCreationFailedError(err.Error(), false)
+		}
+
+		if typedErr, ok := err.(bslcvm.CreationFailedError); ok {
+			return 0, bslcapi.NewVMCreationFailedError(err.Error(), typedErr.CanRetry())
+		}
+
+		if bslcommon.IsTransientError(err) {
+			return 0, bslcapi.NewVMCreationFailedError(err.Error(), true)
+		}
+
 		return 0, bosherr.WrapErrorf(err, "Creating VM with agent ID '%s'", agentID)
 	}
 
 	return VMCID(vm.ID()), nil
 }
 
-func (a CreateVM) updateCloudProperties(cloudProps bslcvm.VMCloudProperties) {
-	if cloudProps.StartCpus > 1 {
-		a.vmCloudProperties.StartCpus = cloudProps.StartCpus
-	}
+// mergeCloudProperties returns the cloud properties given to the action
+// with anything they leave unset taken from the selected profile, then from
+// the configured defaults
+func (a CreateVM) mergeCloudProperties(cloudProps bslcvm.VMCloudProperties) (bslcvm.VMCloudProperties, error) {
+	defaults := a.vmDefaults
 
-	if cloudProps.MaxMemory > 1024 {
-		a.vmCloudProperties.MaxMemory = cloudProps.MaxMemory
-	}
+	if cloudProps.Profile != "" {
+		profile, found := a.vmProfiles[cloudProps.Profile]
+		if !found {
+			return cloudProps, bosherr.Errorf("Expected to find VM profile '%s' in the CPI config", cloudProps.Profile)
+		}
 
-	if cloudProps.Datacenter.Name != a.vmCloudProperties.Datacenter.Name {
-		a.vmCloudProperties.Datacenter.Name = cloudProps.Datacenter.Name
+		defaults = profile.MergeDefaults(defaults)
 	}
 
-	if len(cloudProps.SshKeys) > 0 {
-		a.vmCloudProperties.SshKeys = cloudProps.SshKeys
-	}
+	return cloudProps.MergeDefaults(defaults), nil
 }
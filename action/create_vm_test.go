@@ -8,9 +8,13 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
 	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
 	sldatatypes "github.com/maximilien/softlayer-go/data_types"
@@ -20,13 +24,15 @@ var _ = Describe("CreateVM", func() {
 	var (
 		stemcellFinder *fakestem.FakeFinder
 		vmCreator      *fakevm.FakeCreator
+		logger         boshlog.Logger
 		action         CreateVM
 	)
 
 	BeforeEach(func() {
 		stemcellFinder = &fakestem.FakeFinder{}
 		vmCreator = &fakevm.FakeCreator{}
-		action = NewCreateVM(stemcellFinder, vmCreator)
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		action = NewCreateVM(stemcellFinder, vmCreator, bslcvm.VMCloudProperties{}, nil, logger)
 	})
 
 	Describe("Run", func() {
@@ -95,6 +101,148 @@ var _ = Describe("CreateVM", func() {
 				))
 			})
 
+			Context("when VM defaults and profiles are configured", func() {
+				BeforeEach(func() {
+					vmDefaults := bslcvm.VMCloudProperties{
+						Domain:     "fake-default-domain.com",
+						StartCpus:  1,
+						MaxMemory:  1024,
+						Datacenter: sldatatypes.Datacenter{Name: "fake-default-datacenter"},
+					}
+					vmProfiles := map[string]bslcvm.VMCloudProperties{
+						"large": bslcvm.VMCloudProperties{
+							StartCpus:         8,
+							MaxMemory:         8192,
+							EphemeralDiskSize: 100,
+						},
+					}
+
+					action = NewCreateVM(stemcellFinder, vmCreator, vmDefaults, vmProfiles, logger)
+					vmCreator.CreateVM = fakevm.NewFakeVM(1234)
+				})
+
+				It("fills the cloud properties left unset from the defaults", func() {
+					_, err := action.Run("fake-agent-id", stemcellCID, bslcvm.VMCloudProperties{MaxMemory: 2048}, networks, diskLocality, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(vmCreator.CreateVMCloudProperties).To(Equal(bslcvm.VMCloudProperties{
+						Domain:     "fake-default-domain.com",
+						StartCpus:  1,
+						MaxMemory:  2048,
+						Datacenter: sldatatypes.Datacenter{Name: "fake-default-datacenter"},
+					}))
+				})
+
+				It("fills them from the selected profile before the defaults", func() {
+					cloudProps := bslcvm.VMCloudProperties{Profile: "large", MaxMemory: 16384}
+
+					_, err := action.Run("fake-agent-id", stemcellCID, cloudProps, networks, diskLocality, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(vmCreator.CreateVMCloudProperties).To(Equal(bslcvm.VMCloudProperties{
+						Domain:            "fake-default-domain.com",
+						StartCpus:         8,
+						MaxMemory:         16384,
+						Datacenter:        sldatatypes.Datacenter{Name: "fake-default-datacenter"},
+						EphemeralDiskSize: 100,
+						Profile:           "large",
+					}))
+				})
+
+				It("keeps a localDiskFlag set to false over a profile that sets it to true", func() {
+					localDisks, sanDisks := true, false
+					vmProfiles := map[string]bslcvm.VMCloudProperties{
+						"local": bslcvm.VMCloudProperties{LocalDiskFlag: &localDisks},
+					}
+					action = NewCreateVM(stemcellFinder, vmCreator, bslcvm.VMCloudProperties{}, vmProfiles, logger)
+
+					_, err := action.Run("fake-agent-id", stemcellCID, bslcvm.VMCloudProperties{Profile: "local", LocalDiskFlag: &sanDisks}, networks, diskLocality, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(vmCreator.CreateVMCloudProperties.UsesLocalDisks()).To(BeFalse())
+				})
+
+				It("returns error if the selected profile is not configured", func() {
+					cloudProps := bslcvm.VMCloudProperties{Profile: "fake-profile"}
+
+					_, err := action.Run("fake-agent-id", stemcellCID, cloudProps, networks, diskLocality, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Expected to find VM profile 'fake-profile'"))
+				})
+			})
+
+			It("returns VMCreationFailedError if cloud properties are invalid", func() {
+				vmCreator.CreateErr = bslcommon.InvalidCloudPropertiesError{Problems: []string{"fake-problem"}}
+
+				id, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.Error()).To(ContainSubstring("fake-problem"))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeFalse())
+				Expect(id).To(Equal(VMCID(0)))
+			})
+
+			It("returns VMCreationFailedError if the stemcell is not available in the datacenter", func() {
+				vmCreator.CreateErr = bslcvm.StemcellNotInDatacenterError{StemcellId: 1234, Datacenter: "ams01", Datacenters: []string{"dal05"}}
+
+				id, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.Error()).To(ContainSubstring("not available in datacenter 'ams01'"))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeFalse())
+				Expect(id).To(Equal(VMCID(0)))
+			})
+
+			It("returns VMCreationFailedError that can be retried if the VM failed to be prepared for a transient reason", func() {
+				vmCreator.CreateErr = bslcvm.CreationFailedError{
+					VirtualGuestId: 1234567,
+					Cause:          bslcapi.WrapError(bslcommon.APIError{Path: "fake-path", Attempts: 1, Reason: "connection reset by peer", Transient: true}, "fake-wait-err"),
+				}
+
+				id, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.Error()).To(ContainSubstring("VirtualGuest `1234567`, which was cancelled"))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeTrue())
+				Expect(id).To(Equal(VMCID(0)))
+			})
+
+			It("returns VMCreationFailedError that cannot be retried if the VM failed to be prepared otherwise", func() {
+				vmCreator.CreateErr = bslcvm.CreationFailedError{
+					VirtualGuestId: 1234567,
+					Cause:          errors.New("fake-metadata-err"),
+				}
+
+				_, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeFalse())
+			})
+
+			It("returns VMCreationFailedError that cannot be retried if the VM failed for a transient reason but was kept", func() {
+				vmCreator.CreateErr = bslcvm.CreationFailedError{
+					VirtualGuestId: 1234567,
+					Cause:          bslcommon.APIError{Path: "fake-path", Attempts: 1, Reason: "connection reset by peer", Transient: true},
+					Kept:           true,
+				}
+
+				_, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeFalse())
+			})
+
+			It("returns VMCreationFailedError that can be retried if ordering the VM failed for a transient reason", func() {
+				vmCreator.CreateErr = bslcapi.WrapError(bslcommon.APIError{Path: "fake-path", Attempts: 1, Reason: "fake-reason", Transient: true}, "fake-order-err")
+
+				_, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+				Expect(err.(bslcapi.VMCreationFailedError).CanRetry()).To(BeTrue())
+			})
+
+			It("returns error that cannot be retried if creating VM fails with a message that only looks transient", func() {
+				vmCreator.CreateErr = errors.New("fake-create-err: connection reset by peer")
+
+				_, err := action.Run("fake-agent-id", stemcellCID, vmCloudProp, networks, diskLocality, env)
+				Expect(err).To(HaveOccurred())
+				Expect(err).ToNot(BeAssignableToTypeOf(bslcapi.VMCreationFailedError{}))
+			})
+
 			It("returns error if creating VM fails", func() {
 				vmCreator.CreateErr = errors.New("fake-create-err")
 
@@ -0,0 +1,42 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+)
+
+type CurrentVMID struct {
+	vmFinder   bslcvm.Finder
+	ipResolver util.IPResolver
+}
+
+func NewCurrentVMID(vmFinder bslcvm.Finder, ipResolver util.IPResolver) CurrentVMID {
+	return CurrentVMID{
+		vmFinder:   vmFinder,
+		ipResolver: ipResolver,
+	}
+}
+
+func (a CurrentVMID) Run() (VMCID, error) {
+	ips, err := a.ipResolver.LocalIPs()
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Resolving local IPs")
+	}
+
+	vm, found, err := a.vmFinder.FindByPrimaryIPs(ips)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Finding VM with any of the IPs '%s'", strings.Join(ips, ", "))
+	}
+
+	if found {
+		return VMCID(vm.ID()), nil
+	}
+
+	return 0, bslcapi.NewCloudError(fmt.Sprintf("Could not find a VM with any of the local IPs '%s'", strings.Join(ips, ", ")))
+}
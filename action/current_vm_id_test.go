@@ -0,0 +1,83 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+
+	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
+	fakeutil "github.com/maximilien/bosh-softlayer-cpi/util/fakes"
+)
+
+var _ = Describe("CurrentVMID", func() {
+	var (
+		vmFinder   *fakevm.FakeFinder
+		ipResolver fakeutil.FakeIPResolver
+		action     CurrentVMID
+	)
+
+	BeforeEach(func() {
+		vmFinder = &fakevm.FakeFinder{}
+		ipResolver = fakeutil.FakeIPResolver{LocalIPsIPs: []string{"10.0.0.1", "10.0.0.2"}}
+	})
+
+	JustBeforeEach(func() {
+		action = NewCurrentVMID(vmFinder, ipResolver)
+	})
+
+	Describe("Run", func() {
+		Context("when a VM matches one of the local IPs", func() {
+			BeforeEach(func() {
+				vmFinder.FindByPrimaryIPsVMs = map[string]bslcvm.VM{
+					"10.0.0.2": fakevm.NewFakeVM(1234),
+				}
+			})
+
+			It("returns the cid of that VM", func() {
+				vmCID, err := action.Run()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(vmCID).To(Equal(VMCID(1234)))
+
+				Expect(vmFinder.FindByPrimaryIPsIPs).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+			})
+		})
+
+		Context("when no VM matches the local IPs", func() {
+			It("returns a CloudError", func() {
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(bslcapi.NewCloudError("Could not find a VM with any of the local IPs '10.0.0.1, 10.0.0.2'")))
+			})
+		})
+
+		Context("when resolving the local IPs fails", func() {
+			BeforeEach(func() {
+				ipResolver.LocalIPsErr = errors.New("fake-resolve-err")
+			})
+
+			It("returns error", func() {
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-resolve-err"))
+			})
+		})
+
+		Context("when finding the VM fails", func() {
+			BeforeEach(func() {
+				vmFinder.FindByPrimaryIPsErr = errors.New("fake-find-err")
+			})
+
+			It("returns error", func() {
+				_, err := action.Run()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			})
+		})
+	})
+})
@@ -0,0 +1,31 @@
+package action
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+type DeleteSnapshot struct {
+	snapshotFinder bslcsnap.Finder
+}
+
+func NewDeleteSnapshot(snapshotFinder bslcsnap.Finder) DeleteSnapshot {
+	return DeleteSnapshot{snapshotFinder: snapshotFinder}
+}
+
+func (a DeleteSnapshot) Run(snapshotCID SnapshotCID) (interface{}, error) {
+	snapshot, found, err := a.snapshotFinder.Find(int(snapshotCID))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding snapshot '%s'", snapshotCID)
+	}
+
+	if found {
+		err := snapshot.Delete()
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Deleting snapshot '%s'", snapshotCID)
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,79 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	fakesnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot/fakes"
+)
+
+var _ = Describe("DeleteSnapshot", func() {
+	var (
+		snapshotFinder *fakesnap.FakeFinder
+		action         DeleteSnapshot
+	)
+
+	BeforeEach(func() {
+		snapshotFinder = &fakesnap.FakeFinder{}
+		action = NewDeleteSnapshot(snapshotFinder)
+	})
+
+	Describe("Run", func() {
+		It("tries to find snapshot with given snapshot cid", func() {
+			_, err := action.Run(5678)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshotFinder.FindID).To(Equal(5678))
+		})
+
+		Context("when snapshot is found with given snapshot cid", func() {
+			var (
+				snapshot *fakesnap.FakeSnapshot
+			)
+
+			BeforeEach(func() {
+				snapshot = fakesnap.NewFakeSnapshot(5678)
+				snapshotFinder.FindSnapshot = snapshot
+				snapshotFinder.FindFound = true
+			})
+
+			It("deletes snapshot", func() {
+				_, err := action.Run(5678)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(snapshot.DeleteCalled).To(BeTrue())
+			})
+
+			It("returns error if deleting snapshot fails", func() {
+				snapshot.DeleteErr = errors.New("fake-delete-err")
+
+				_, err := action.Run(5678)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-delete-err"))
+			})
+		})
+
+		Context("when snapshot is not found with given cid", func() {
+			It("does not return error", func() {
+				snapshotFinder.FindFound = false
+
+				_, err := action.Run(5678)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when snapshot finding fails", func() {
+			It("returns error", func() {
+				snapshotFinder.FindErr = errors.New("fake-find-err")
+
+				_, err := action.Run(5678)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			})
+		})
+	})
+})
@@ -2,16 +2,30 @@ package action
 
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
 
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 )
 
+const deleteStemcellLogTag = "DeleteStemcell"
+
 type DeleteStemcell struct {
-	stemcellFinder bslcstem.Finder
+	stemcellFinder   bslcstem.Finder
+	stemcellRegistry bslcstem.Registry
+
+	keepSharedImages bool
+	logger           boshlog.Logger
 }
 
-func NewDeleteStemcell(stemcellFinder bslcstem.Finder) DeleteStemcell {
-	return DeleteStemcell{stemcellFinder: stemcellFinder}
+func NewDeleteStemcell(stemcellFinder bslcstem.Finder, stemcellRegistry bslcstem.Registry, keepSharedImages bool, logger boshlog.Logger) DeleteStemcell {
+	return DeleteStemcell{
+		stemcellFinder:   stemcellFinder,
+		stemcellRegistry: stemcellRegistry,
+		keepSharedImages: keepSharedImages,
+		logger:           logger,
+	}
 }
 
 func (a DeleteStemcell) Run(stemcellCID StemcellCID) (interface{}, error) {
@@ -20,11 +34,30 @@ func (a DeleteStemcell) Run(stemcellCID StemcellCID) (interface{}, error) {
 		return nil, bosherr.WrapErrorf(err, "Finding stemcell '%s'", stemcellCID)
 	}
 
-	if found {
-		err := stemcell.Delete()
-		if err != nil {
-			return nil, bosherr.WrapErrorf(err, "Deleting stemcell '%s'", stemcellCID)
+	// Forgotten before deleting so that, should deleting fail, a retry looks
+	// the stemcell up on SoftLayer again. Only logged like in create_stemcell
+	// since a record left behind is dropped once the stemcell is not found.
+	err = a.stemcellRegistry.Delete(int(stemcellCID))
+	if err != nil {
+		a.logger.Warn(deleteStemcellLogTag, "Removing stemcell '%s' from registry: %s", stemcellCID, err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	if a.keepSharedImages && stemcell.Kind() == bslcstem.VirtualDiskImageKind {
+		a.logger.Info(deleteStemcellLogTag, "Keeping VirtualDiskImage '%s' of stemcell since shared images are kept", stemcellCID)
+		return nil, nil
+	}
+
+	err = stemcell.Delete()
+	if err != nil {
+		if inUseErr, ok := err.(bslcstem.ImageInUseError); ok {
+			return nil, bslcapi.NewCloudError(inUseErr.Error())
 		}
+
+		return nil, bosherr.WrapErrorf(err, "Deleting stemcell '%s'", stemcellCID)
 	}
 
 	return nil, nil
@@ -8,18 +8,26 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
 	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
 )
 
 var _ = Describe("DeleteStemcell", func() {
 	var (
-		stemcellFinder *fakestem.FakeFinder
-		action         DeleteStemcell
+		stemcellFinder   *fakestem.FakeFinder
+		stemcellRegistry *fakestem.FakeRegistry
+		action           DeleteStemcell
 	)
 
 	BeforeEach(func() {
 		stemcellFinder = &fakestem.FakeFinder{}
-		action = NewDeleteStemcell(stemcellFinder)
+		stemcellRegistry = fakestem.NewFakeRegistry()
+		stemcellRegistry.Records[1234] = bslcstem.RegistryRecord{Kind: fakestem.FakeStemcellKind, Uuid: "fake-stemcell-id"}
+		action = NewDeleteStemcell(stemcellFinder, stemcellRegistry, false, boshlog.NewLogger(boshlog.LevelNone))
 	})
 
 	Describe("Run", func() {
@@ -48,6 +56,21 @@ var _ = Describe("DeleteStemcell", func() {
 				Expect(stemcell.DeleteCalled).To(BeTrue())
 			})
 
+			It("removes stemcell from registry", func() {
+				_, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stemcellRegistry.Records).To(BeEmpty())
+			})
+
+			It("deletes stemcell even if removing it from registry fails", func() {
+				stemcellRegistry.DeleteErr = errors.New("fake-registry-delete-err")
+
+				_, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stemcell.DeleteCalled).To(BeTrue())
+			})
+
 			It("returns error if deleting stemcell fails", func() {
 				stemcell.DeleteErr = errors.New("fake-delete-err")
 
@@ -55,6 +78,43 @@ var _ = Describe("DeleteStemcell", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-delete-err"))
 			})
+
+			It("returns a cloud error if the image is still in use", func() {
+				stemcell.DeleteErr = bslcstem.ImageInUseError{Id: 1234, VirtualGuestIds: []int{5678}}
+
+				_, err := action.Run(1234)
+				Expect(err).To(Equal(bslcapi.NewCloudError("VirtualDiskImage `1234` is still used by VirtualGuest(s) 5678")))
+			})
+		})
+
+		Context("when shared images are kept", func() {
+			var (
+				stemcell *fakestem.FakeStemcell
+			)
+
+			BeforeEach(func() {
+				action = NewDeleteStemcell(stemcellFinder, stemcellRegistry, true, boshlog.NewLogger(boshlog.LevelNone))
+				stemcellFinder.FindFound = true
+			})
+
+			It("does not delete a virtual disk image stemcell", func() {
+				stemcell = fakestem.NewFakeStemcell(1234, "fake-stemcell-id", bslcstem.VirtualDiskImageKind)
+				stemcellFinder.FindStemcell = stemcell
+
+				_, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stemcell.DeleteCalled).To(BeFalse())
+				Expect(stemcellRegistry.Records).To(BeEmpty())
+			})
+
+			It("deletes other stemcells", func() {
+				stemcell = fakestem.NewFakeStemcell(1234, "fake-stemcell-id", bslcstem.VirtualGuestDeviceTemplateGroupKind)
+				stemcellFinder.FindStemcell = stemcell
+
+				_, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stemcell.DeleteCalled).To(BeTrue())
+			})
 		})
 
 		Context("when stemcell is not found with given cid", func() {
@@ -63,6 +123,7 @@ var _ = Describe("DeleteStemcell", func() {
 
 				_, err := action.Run(1234)
 				Expect(err).ToNot(HaveOccurred())
+				Expect(stemcellRegistry.Records).To(BeEmpty())
 			})
 		})
 
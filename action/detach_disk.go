@@ -3,6 +3,8 @@ package action
 import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
 	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
@@ -26,7 +28,7 @@ func (a DetachDisk) Run(vmCID VMCID, diskCID DiskCID) (interface{}, error) {
 	}
 
 	if !found {
-		return nil, bosherr.Errorf("Expected to find VM '%s'", vmCID)
+		return nil, bslcapi.NewVMNotFoundError(vmCID.String())
 	}
 
 	disk, found, err := a.diskFinder.Find(int(diskCID))
@@ -35,11 +37,15 @@ func (a DetachDisk) Run(vmCID VMCID, diskCID DiskCID) (interface{}, error) {
 	}
 
 	if !found {
-		return nil, bosherr.Errorf("Expected to find disk '%s'", diskCID)
+		return nil, bslcapi.NewDiskNotFoundError(diskCID.String())
 	}
 
 	err = vm.DetachDisk(disk)
 	if err != nil {
+		if _, ok := err.(bslcvm.DiskNotAttachedError); ok {
+			return nil, bslcapi.NewDiskNotAttachedError(vmCID.String(), diskCID.String())
+		}
+
 		return nil, bosherr.WrapErrorf(err, "Detaching disk '%s' to VM '%s'", diskCID, vmCID)
 	}
 
@@ -8,6 +8,9 @@ import (
 
 	. "github.com/maximilien/bosh-softlayer-cpi/action"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+
 	fakedisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk/fakes"
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
 )
@@ -84,6 +87,15 @@ var _ = Describe("DetachDisk", func() {
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("fake-detach-disk-err"))
 				})
+
+				It("returns DiskNotAttachedError if disk is not attached to VM", func() {
+					vm.DetachDiskErr = bslcvm.DiskNotAttachedError{}
+
+					_, err := action.Run(1234, 1234)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Disk '1234' not attached to VM '1234'"))
+					Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::DiskNotAttached"))
+				})
 			})
 
 			Context("when disk is not found with given cid", func() {
@@ -92,7 +104,8 @@ var _ = Describe("DetachDisk", func() {
 
 					_, err := action.Run(1234, 1234)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("Expected to find disk"))
+					Expect(err.Error()).To(Equal("Disk '1234' not found"))
+					Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::DiskNotFound"))
 				})
 			})
 
@@ -113,7 +126,8 @@ var _ = Describe("DetachDisk", func() {
 
 				_, err := action.Run(1234, 1234)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("Expected to find VM"))
+				Expect(err.Error()).To(Equal("VM '1234' not found"))
+				Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::VMNotFound"))
 			})
 		})
 
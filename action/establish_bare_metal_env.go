@@ -3,10 +3,10 @@ package action
 import (
 	"os"
 	"strconv"
-	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	bm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 const (
@@ -17,65 +17,95 @@ const (
 	DOMAIN_CON     = "SL_BARE_METAL_DOMAIN"
 	OS_CON         = "SL_BARE_METAL_OS"
 	DATACENTER_CON = "SL_DATA_CENTER"
-
-	MAX_RETRIES = 120
 )
 
 type EstablishBareMetalEnv struct {
 	bmCreator bm.BaremetalCreator
 	bmFinder  bm.BaremetalFinder
+
+	waitOptions bslcommon.WaitOptions
 }
 
-func NewEstablishBareMetalEnv(bmCreator bm.BaremetalCreator, bmFinder bm.BaremetalFinder) EstablishBareMetalEnv {
+func NewEstablishBareMetalEnv(bmCreator bm.BaremetalCreator, bmFinder bm.BaremetalFinder, waitOptions bslcommon.WaitOptions) EstablishBareMetalEnv {
 	return EstablishBareMetalEnv{
-		bmCreator: bmCreator,
-		bmFinder:  bmFinder,
+		bmCreator:   bmCreator,
+		bmFinder:    bmFinder,
+		waitOptions: waitOptions,
 	}
 }
 
-func (b EstablishBareMetalEnv) Run() (interface{}, error) {
+func (b EstablishBareMetalEnv) Run(cloudProps bm.BareMetalCloudProperties) (bm.BaremetalServer, error) {
 
 	// Step #1, use baremetal creator to create a new baremetal server
-	memoryS := os.Getenv(MEMORY_CON)
-	processorS := os.Getenv(PROCESSOR_CON)
-	disksizeS := os.Getenv(DISK_CON)
-	host := os.Getenv(HOST_CON)
-	domain := os.Getenv(DOMAIN_CON)
-	ostype := os.Getenv(OS_CON)
-	datacenter := os.Getenv(DATACENTER_CON)
-
-	memory, err := strconv.Atoi(memoryS)
+	envCloudProps, err := bareMetalCloudPropertiesFromEnv(cloudProps)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Invalid baremetal creation memory parameter: %s", memoryS)
+		return bm.BaremetalServer{}, bosherr.WrapError(err, "Reading default baremetal cloud properties from the environment")
 	}
 
-	processor, err := strconv.Atoi(processorS)
+	baremetal, err := b.bmCreator.Create(cloudProps.MergeDefaults(envCloudProps))
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Invalid baremetal creation processor parameter: %s", processorS)
+		return bm.BaremetalServer{}, bosherr.WrapError(err, "Create baremetal server error")
 	}
 
-	disksize, err := strconv.Atoi(disksizeS)
+	// Step #2, wait for the server to be provisioned, cancelling the order
+	// rather than leaving it billed when it takes too long
+	provisioned, err := b.bmFinder.WaitForProvisioning(baremetal.GlobalIdentifier, b.waitOptions)
 	if err != nil {
-		return nil, bosherr.WrapErrorf(err, "Invalid baremetal creation disk size parameter: %s", disksizeS)
+		return bm.BaremetalServer{}, bosherr.WrapErrorf(err, "Waiting for baremetal server '%s' to be provisioned", baremetal.GlobalIdentifier)
+	}
+
+	if !provisioned {
+		err = b.bmCreator.Cancel(baremetal.GlobalIdentifier, b.waitOptions)
+		if err != nil {
+			return bm.BaremetalServer{}, bosherr.WrapErrorf(err, "Cancelling baremetal server '%s' which was not provisioned within %s", baremetal.GlobalIdentifier, b.waitOptions.Timeout)
+		}
+
+		return bm.BaremetalServer{}, bosherr.Errorf("Baremetal server '%s' was not provisioned within %s and has been cancelled", baremetal.GlobalIdentifier, b.waitOptions.Timeout)
 	}
 
-	baremetal, err := b.bmCreator.Create(memory, processor, disksize, host, domain, ostype, datacenter)
+	// Step #3, report which server was provisioned and how to reach it
+	server, err := b.bmFinder.Describe(baremetal.GlobalIdentifier)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Create baremetal server error")
+		return bm.BaremetalServer{}, bosherr.WrapErrorf(err, "Describing baremetal server '%s'", baremetal.GlobalIdentifier)
 	}
 
-	for step := 1; baremetal.ProvisionDate == nil || step >= MAX_RETRIES; step++ {
-		time.Sleep(60 * time.Second)
+	return server, nil
+}
 
-		baremetal, err = b.bmFinder.Find(baremetal.GlobalIdentifier)
+// bareMetalCloudPropertiesFromEnv reads the SL_BARE_METAL_* environment variables,
+// which only provide defaults for what the cloud properties leave unset.
+// Numbers are parsed only when they are needed.
+func bareMetalCloudPropertiesFromEnv(cloudProps bm.BareMetalCloudProperties) (bm.BareMetalCloudProperties, error) {
+	envCloudProps := bm.BareMetalCloudProperties{
+		Hostname:   os.Getenv(HOST_CON),
+		Domain:     os.Getenv(DOMAIN_CON),
+		Os:         os.Getenv(OS_CON),
+		Datacenter: os.Getenv(DATACENTER_CON),
+	}
+
+	numbers := []struct {
+		name  string
+		isSet bool
+		value *int
+	}{
+		{MEMORY_CON, cloudProps.Memory != 0, &envCloudProps.Memory},
+		{PROCESSOR_CON, cloudProps.Processor != 0, &envCloudProps.Processor},
+		{DISK_CON, cloudProps.DiskSize != 0, &envCloudProps.DiskSize},
+	}
+
+	for _, number := range numbers {
+		value := os.Getenv(number.name)
+		if number.isSet || value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
 		if err != nil {
-			return nil, bosherr.WrapErrorf(err, "Can not find the baremetal server of id: %s", baremetal.GlobalIdentifier)
+			return bm.BareMetalCloudProperties{}, bosherr.WrapErrorf(err, "Parsing %s '%s'", number.name, value)
 		}
-	}
 
-	if baremetal.ProvisionDate == nil {
-		return nil, bosherr.WrapError(err, "Baremetal server creation timeout")
+		*number.value = parsed
 	}
 
-	return nil, nil
+	return envCloudProps, nil
 }
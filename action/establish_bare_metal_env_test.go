@@ -0,0 +1,144 @@
+package action_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("EstablishBareMetalEnv", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		sleeper         *util.RecordingNoopSleeper
+		logger          boshlog.Logger
+		waitOptions     bslcommon.WaitOptions
+		action          EstablishBareMetalEnv
+		cloudProps      bslcbm.BareMetalCloudProperties
+	)
+
+	var (
+		orderedResponse     = []byte(`{"id": 1, "globalIdentifier": "fake-id"}`)
+		provisionedResponse = []byte(`{
+			"id": 1,
+			"globalIdentifier": "fake-id",
+			"provisionDate": "2014-11-30T23:20:47-08:00",
+			"primaryIpAddress": "1.1.1.1",
+			"primaryBackendIpAddress": "10.1.1.1",
+			"operatingSystem": {"passwords": [{"id": 1234, "username": "root"}]}
+		}`)
+		billedResponse    = []byte(`{"id": 1, "accountId": 1, "billingItem": {"id": 1234567}}`)
+		cancelledResponse = []byte(`{"id": 1234, "items": [{"billingItemId": 1234567, "immediateCancellationFlag": true}]}`)
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		sleeper = util.NewRecordingNoopSleeper()
+		waitOptions = bslcommon.WaitOptions{Timeout: 2 * time.Minute, PollingInterval: 1 * time.Minute, Sleeper: sleeper}
+
+		action = NewEstablishBareMetalEnv(
+			bslcbm.NewBaremetalCreator(softLayerClient, logger),
+			bslcbm.NewBaremetalFinder(softLayerClient, logger),
+			waitOptions,
+		)
+
+		cloudProps = bslcbm.BareMetalCloudProperties{
+			Memory:     2,
+			Processor:  1,
+			DiskSize:   100,
+			Hostname:   "fake-host",
+			Domain:     "fake-domain",
+			Os:         "fake-os",
+			Datacenter: "fake-datacenter",
+		}
+	})
+
+	AfterEach(func() {
+		for _, name := range []string{MEMORY_CON, PROCESSOR_CON, DISK_CON, HOST_CON, DOMAIN_CON, OS_CON, DATACENTER_CON} {
+			os.Unsetenv(name)
+		}
+	})
+
+	Describe("Run", func() {
+		It("returns the provisioned server once it has a provision date", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{orderedResponse, orderedResponse, provisionedResponse, provisionedResponse}
+
+			server, err := action.Run(cloudProps)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.GlobalIdentifier).To(Equal("fake-id"))
+			Expect(server.PrimaryIpAddress).To(Equal("1.1.1.1"))
+			Expect(server.PrimaryBackendIpAddress).To(Equal("10.1.1.1"))
+			Expect(server.RootCredentials).To(Equal("SoftLayer_Software_Component_Password/1234"))
+			Expect(sleeper.SleptTimes()).To(Equal([]time.Duration{1 * time.Minute}))
+		})
+
+		It("falls back to environment variables for unset cloud properties", func() {
+			os.Setenv(MEMORY_CON, "4")
+			os.Setenv(DATACENTER_CON, "fake-env-datacenter")
+			cloudProps.Memory = 0
+			cloudProps.Datacenter = ""
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{orderedResponse, provisionedResponse, provisionedResponse}
+
+			_, err := action.Run(cloudProps)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error listing the cloud properties that are missing", func() {
+			_, err := action.Run(bslcbm.BareMetalCloudProperties{Hostname: "fake-host"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("memory must be a positive number of GB, it is set to be 0"))
+			Expect(err.Error()).To(ContainSubstring("datacenter is required and cannot be empty"))
+		})
+
+		It("returns error if an environment variable needed as a default is not a number", func() {
+			os.Setenv(PROCESSOR_CON, "fake-processor")
+			cloudProps.Processor = 0
+
+			_, err := action.Run(cloudProps)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing SL_BARE_METAL_PROCESSOR 'fake-processor'"))
+		})
+
+		It("cancels the billing item of the server when it is not provisioned in time", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{orderedResponse, orderedResponse, orderedResponse, billedResponse, cancelledResponse}
+
+			recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+			action = NewEstablishBareMetalEnv(
+				bslcbm.NewBaremetalCreator(recordingClient, logger),
+				bslcbm.NewBaremetalFinder(recordingClient, logger),
+				waitOptions,
+			)
+
+			_, err := action.Run(cloudProps)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Baremetal server 'fake-id' was not provisioned within 2m0s and has been cancelled"))
+			Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(5))
+
+			body, found := recordingClient.RequestTo("SoftLayer_Billing_Item_Cancellation_Request/createObject.json")
+			Expect(found).To(BeTrue())
+			Expect(string(body)).To(ContainSubstring(`"billingItemId":1234567`))
+		})
+
+		It("returns error when the order cannot be cancelled", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{orderedResponse, orderedResponse, orderedResponse, billedResponse, []byte(`{"error": "fake-error", "code": "SoftLayer_Exception_Public"}`)}
+
+			_, err := action.Run(cloudProps)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Cancelling baremetal server 'fake-id'"))
+		})
+	})
+})
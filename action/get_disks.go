@@ -0,0 +1,40 @@
+package action
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
+	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+)
+
+type GetDisks struct {
+	vmFinder bslcvm.Finder
+}
+
+func NewGetDisks(vmFinder bslcvm.Finder) GetDisks {
+	return GetDisks{vmFinder: vmFinder}
+}
+
+func (a GetDisks) Run(vmCID VMCID) ([]DiskCID, error) {
+	vm, found, err := a.vmFinder.Find(int(vmCID))
+	if err != nil {
+		return []DiskCID{}, bosherr.WrapErrorf(err, "Finding VM '%s'", vmCID)
+	}
+
+	if !found {
+		return []DiskCID{}, bslcapi.NewVMNotFoundError(vmCID.String())
+	}
+
+	diskIDs, err := vm.GetDisks()
+	if err != nil {
+		return []DiskCID{}, bosherr.WrapErrorf(err, "Getting disks of VM '%s'", vmCID)
+	}
+
+	diskCIDs := []DiskCID{}
+	for _, diskID := range diskIDs {
+		diskCIDs = append(diskCIDs, DiskCID(diskID))
+	}
+
+	return diskCIDs, nil
+}
@@ -0,0 +1,94 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
+	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
+)
+
+var _ = Describe("GetDisks", func() {
+	var (
+		vmFinder *fakevm.FakeFinder
+		action   GetDisks
+	)
+
+	BeforeEach(func() {
+		vmFinder = &fakevm.FakeFinder{}
+		action = NewGetDisks(vmFinder)
+	})
+
+	Describe("Run", func() {
+		It("tries to find VM with given VM cid", func() {
+			vmFinder.FindFound = true
+			vmFinder.FindVM = fakevm.NewFakeVM(1234)
+
+			_, err := action.Run(1234)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(vmFinder.FindID).To(Equal(1234))
+		})
+
+		Context("when VM is found with given VM cid", func() {
+			var (
+				vm *fakevm.FakeVM
+			)
+
+			BeforeEach(func() {
+				vm = fakevm.NewFakeVM(1234)
+				vmFinder.FindVM = vm
+				vmFinder.FindFound = true
+			})
+
+			It("returns the disk cids of the VM", func() {
+				vm.GetDisksDiskIDs = []int{1234, 5678}
+
+				diskCIDs, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskCIDs).To(Equal([]DiskCID{1234, 5678}))
+
+				Expect(vm.GetDisksCalled).To(BeTrue())
+			})
+
+			It("returns an empty list when the VM has no disks", func() {
+				diskCIDs, err := action.Run(1234)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskCIDs).To(Equal([]DiskCID{}))
+			})
+
+			It("returns error if getting the disks fails", func() {
+				vm.GetDisksErr = errors.New("fake-get-disks-err")
+
+				_, err := action.Run(1234)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-get-disks-err"))
+			})
+		})
+
+		Context("when VM is not found with given cid", func() {
+			It("returns VMNotFoundError", func() {
+				vmFinder.FindFound = false
+
+				_, err := action.Run(1234)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(bslcapi.NewVMNotFoundError("1234")))
+			})
+		})
+
+		Context("when VM finding fails", func() {
+			It("returns error", func() {
+				vmFinder.FindErr = errors.New("fake-find-err")
+
+				_, err := action.Run(1234)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			})
+		})
+	})
+})
@@ -35,6 +35,8 @@ func (ns Networks) AsVMNetworks() bslcvm.Networks {
 			DNS:     network.DNS,
 			Default: network.Default,
 
+			MAC: network.MAC,
+
 			CloudProperties: network.CloudProperties,
 		}
 	}
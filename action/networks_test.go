@@ -26,6 +26,8 @@ var _ = Describe("Networks", func() {
 				DNS:     []string{"fake-net1-dns"},
 				Default: []string{"fake-net1-default"},
 
+				MAC: "fake-net1-mac",
+
 				CloudProperties: map[string]interface{}{
 					"fake-net1-cp-key": "fake-net1-cp-value",
 				},
@@ -50,6 +52,8 @@ var _ = Describe("Networks", func() {
 					DNS:     []string{"fake-net1-dns"},
 					Default: []string{"fake-net1-default"},
 
+					MAC: "fake-net1-mac",
+
 					CloudProperties: map[string]interface{}{
 						"fake-net1-cp-key": "fake-net1-cp-value",
 					},
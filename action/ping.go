@@ -0,0 +1,37 @@
+package action
+
+import (
+	"fmt"
+
+	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+)
+
+const activeAccountStatus = "Active"
+
+type Ping struct {
+	softLayerClient sl.Client
+}
+
+func NewPing(softLayerClient sl.Client) Ping {
+	return Ping{softLayerClient: softLayerClient}
+}
+
+func (a Ping) Run() (string, error) {
+	accountService, err := a.softLayerClient.GetSoftLayer_Account_Service()
+	if err != nil {
+		return "", bslcapi.NewCloudError(fmt.Sprintf("Creating SoftLayer AccountService from client: %s", err.Error()))
+	}
+
+	accountStatus, err := accountService.GetAccountStatus()
+	if err != nil {
+		return "", bslcapi.NewCloudError(fmt.Sprintf("Checking SoftLayer credentials: %s", err.Error()))
+	}
+
+	if accountStatus.Name != activeAccountStatus {
+		return "", bslcapi.NewCloudError(fmt.Sprintf("SoftLayer account status is '%s'", accountStatus.Name))
+	}
+
+	return "pong", nil
+}
@@ -0,0 +1,54 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("Ping", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		action          Ping
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		action = NewPing(softLayerClient)
+	})
+
+	Describe("Run", func() {
+		It("returns pong when the SoftLayer account is active", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"id": 1001, "name": "Active"}`)
+
+			result, err := action.Run()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("pong"))
+		})
+
+		It("returns a CloudError when the SoftLayer account is not active", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"id": 1003, "name": "Suspended"}`)
+
+			_, err := action.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::CloudError"))
+			Expect(err.Error()).To(ContainSubstring("Suspended"))
+		})
+
+		It("returns a CloudError when the credentials cannot be checked", func() {
+			softLayerClient.DoRawHttpRequestError = errors.New("fake-status-err")
+
+			_, err := action.Run()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::CloudError"))
+			Expect(err.Error()).To(ContainSubstring("fake-status-err"))
+		})
+	})
+})
@@ -1,6 +1,9 @@
 package action
 
 import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 )
 
@@ -13,5 +16,19 @@ func NewSetVMMetadata(vmFinder bslcvm.Finder) SetVMMetadata {
 }
 
 func (a SetVMMetadata) Run(vmCID VMCID, metadata bslcvm.VMMetadata) (interface{}, error) {
+	vm, found, err := a.vmFinder.Find(int(vmCID))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding vm '%s'", vmCID)
+	}
+
+	if !found {
+		return nil, bslcapi.NewVMNotFoundError(vmCID.String())
+	}
+
+	err = vm.SetMetadata(metadata)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Setting metadata on vm '%s'", vmCID)
+	}
+
 	return nil, nil
 }
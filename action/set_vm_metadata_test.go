@@ -1,11 +1,16 @@
 package action_test
 
 import (
-	. "github.com/maximilien/bosh-softlayer-cpi/action"
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
 	bslcvm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
 )
 
@@ -13,17 +18,72 @@ var _ = Describe("SetVMMetadata", func() {
 	var (
 		vmFinder *fakevm.FakeFinder
 		action   SetVMMetadata
+		metadata bslcvm.VMMetadata
 	)
 
 	BeforeEach(func() {
 		vmFinder = &fakevm.FakeFinder{}
 		action = NewSetVMMetadata(vmFinder)
+		metadata = bslcvm.VMMetadata{"job": "fake-job", "index": "0"}
 	})
 
 	Describe("Run", func() {
-		It("does not do anything and return no error", func() {
-			_, err := action.Run(1234, bslcvm.VMMetadata{})
+		It("tries to find VM with given VM CID", func() {
+			vmFinder.FindVM = fakevm.NewFakeVM(1234)
+			vmFinder.FindFound = true
+
+			_, err := action.Run(1234, metadata)
 			Expect(err).ToNot(HaveOccurred())
+
+			Expect(vmFinder.FindID).To(Equal(1234))
+		})
+
+		Context("when VM is found with given VM CID", func() {
+			var (
+				vm *fakevm.FakeVM
+			)
+
+			BeforeEach(func() {
+				vm = fakevm.NewFakeVM(1234)
+				vmFinder.FindVM = vm
+				vmFinder.FindFound = true
+			})
+
+			It("sets metadata on the VM", func() {
+				_, err := action.Run(1234, metadata)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(vm.SetMetadataCalled).To(BeTrue())
+				Expect(vm.VMMetadata).To(Equal(metadata))
+			})
+
+			It("returns error if setting metadata fails", func() {
+				vm.SetMetadataErr = errors.New("fake-set-metadata-err")
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-set-metadata-err"))
+			})
+		})
+
+		Context("when VM is not found with given VM CID", func() {
+			It("returns VMNotFoundError", func() {
+				vmFinder.FindFound = false
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(bslcapi.CloudError).Type()).To(Equal("Bosh::Clouds::VMNotFound"))
+			})
+		})
+
+		Context("when VM finding fails", func() {
+			It("returns error", func() {
+				vmFinder.FindErr = errors.New("fake-find-err")
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			})
 		})
 	})
 })
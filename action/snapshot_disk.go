@@ -0,0 +1,40 @@
+package action
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
+	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+type SnapshotDisk struct {
+	diskFinder      bslcdisk.Finder
+	snapshotCreator bslcsnap.Creator
+}
+
+func NewSnapshotDisk(diskFinder bslcdisk.Finder, snapshotCreator bslcsnap.Creator) SnapshotDisk {
+	return SnapshotDisk{
+		diskFinder:      diskFinder,
+		snapshotCreator: snapshotCreator,
+	}
+}
+
+func (a SnapshotDisk) Run(diskCID DiskCID, metadata bslcsnap.SnapshotMetadata) (SnapshotCID, error) {
+	disk, found, err := a.diskFinder.Find(int(diskCID))
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	if !found {
+		return 0, bslcapi.NewDiskNotFoundError(diskCID.String())
+	}
+
+	snapshot, err := a.snapshotCreator.Create(disk.ID(), metadata)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Snapshotting disk '%s'", diskCID)
+	}
+
+	return SnapshotCID(snapshot.ID()), nil
+}
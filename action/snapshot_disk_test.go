@@ -0,0 +1,92 @@
+package action_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/action"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+
+	fakedisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk/fakes"
+	fakesnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot/fakes"
+)
+
+var _ = Describe("SnapshotDisk", func() {
+	var (
+		diskFinder      *fakedisk.FakeFinder
+		snapshotCreator *fakesnap.FakeCreator
+		action          SnapshotDisk
+		metadata        bslcsnap.SnapshotMetadata
+	)
+
+	BeforeEach(func() {
+		diskFinder = &fakedisk.FakeFinder{}
+		snapshotCreator = &fakesnap.FakeCreator{}
+		action = NewSnapshotDisk(diskFinder, snapshotCreator)
+
+		metadata = bslcsnap.SnapshotMetadata{
+			"deployment": "fake-deployment",
+			"job":        "fake-job",
+			"index":      0,
+		}
+	})
+
+	Describe("Run", func() {
+		It("tries to find disk with given disk cid", func() {
+			_, err := action.Run(1234, metadata)
+			Expect(err).To(HaveOccurred())
+
+			Expect(diskFinder.FindID).To(Equal(1234))
+		})
+
+		Context("when disk is found with given disk cid", func() {
+			BeforeEach(func() {
+				diskFinder.FindDisk = fakedisk.NewFakeDisk(1234)
+				diskFinder.FindFound = true
+			})
+
+			It("creates a snapshot of the disk with the director metadata and returns its cid", func() {
+				snapshotCreator.CreateSnapshot = fakesnap.NewFakeSnapshot(5678)
+
+				snapshotCID, err := action.Run(1234, metadata)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(snapshotCID).To(Equal(SnapshotCID(5678)))
+
+				Expect(snapshotCreator.CreateDiskID).To(Equal(1234))
+				Expect(snapshotCreator.CreateMetadata).To(Equal(metadata))
+			})
+
+			It("returns error if creating the snapshot fails", func() {
+				snapshotCreator.CreateErr = errors.New("fake-create-err")
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+			})
+		})
+
+		Context("when disk is not found with given cid", func() {
+			It("returns DiskNotFoundError", func() {
+				diskFinder.FindFound = false
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(bslcapi.NewDiskNotFoundError("1234")))
+			})
+		})
+
+		Context("when disk finding fails", func() {
+			It("returns error", func() {
+				diskFinder.FindErr = errors.New("fake-find-err")
+
+				_, err := action.Run(1234, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			})
+		})
+	})
+})
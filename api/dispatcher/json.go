@@ -107,16 +107,18 @@ func (c JSON) buildCloudError(err error) []byte {
 		Error: &ResponseError{},
 	}
 
-	if typedErr, ok := err.(bslcapi.CloudError); ok {
-		respErr.Error.Type = typedErr.Type()
+	// Actions wrap the errors they get, so the type and whether the call can
+	// be retried come from the outermost error in the chain that tells
+	if typedErr, ok := bslcapi.FindError(err, isCloudError); ok {
+		respErr.Error.Type = typedErr.(bslcapi.CloudError).Type()
 	} else {
 		respErr.Error.Type = jsonCloudErrorType
 	}
 
 	respErr.Error.Message = err.Error()
 
-	if typedErr, ok := err.(bslcapi.RetryableError); ok {
-		respErr.Error.CanRetry = typedErr.CanRetry()
+	if typedErr, ok := bslcapi.FindError(err, isRetryableError); ok {
+		respErr.Error.CanRetry = typedErr.(bslcapi.RetryableError).CanRetry()
 	}
 
 	respErrBytes, err := json.Marshal(respErr)
@@ -164,3 +166,13 @@ func (c JSON) buildNotImplementedError() []byte {
 
 	return respErrBytes
 }
+
+func isCloudError(err error) bool {
+	_, ok := err.(bslcapi.CloudError)
+	return ok
+}
+
+func isRetryableError(err error) bool {
+	_, ok := err.(bslcapi.RetryableError)
+	return ok
+}
@@ -7,6 +7,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/maximilien/bosh-softlayer-cpi/api/dispatcher"
+
+	fakeapi "github.com/maximilien/bosh-softlayer-cpi/api/fakes"
 )
 
 type valueType struct {
@@ -148,6 +150,15 @@ var _ = Describe("JSONCaller", func() {
 			))
 		})
 
+		It("returns typed errors returned by action as is", func() {
+			expectedErr := fakeapi.NewFakeCloudError("fake-type", "fake-run-error")
+
+			action := &actionWithOptionalRunArgument{Err: expectedErr}
+
+			_, err := caller.Call(action, []interface{}{"setup"})
+			Expect(err).To(Equal(expectedErr))
+		})
+
 		It("handles optional arguments when not passed in", func() {
 			action := &actionWithOptionalRunArgument{}
 
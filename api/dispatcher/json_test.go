@@ -10,6 +10,8 @@ import (
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+
 	fakeaction "github.com/maximilien/bosh-softlayer-cpi/action/fakes"
 	fakedisp "github.com/maximilien/bosh-softlayer-cpi/api/dispatcher/fakes"
 	fakeapi "github.com/maximilien/bosh-softlayer-cpi/api/fakes"
@@ -158,6 +160,44 @@ var _ = Describe("JSON", func() {
 					})
 				})
 
+				Context("when action error wraps a CloudError", func() {
+					BeforeEach(func() {
+						caller.CallErr = bslcapi.WrapError(fakeapi.NewFakeCloudError("fake-type", "fake-message"), "fake-wrap-message")
+					})
+
+					It("returns error with the type of the wrapped error", func() {
+						response := dispatcher.Dispatch([]byte(`{"method":"fake-action","arguments":["fake-arg"]}`))
+						Expect(response).To(MatchJSON(`{
+							"result": null,
+              "error": {
+                "type":"fake-type",
+                "message":"fake-wrap-message: fake-message",
+                "ok_to_retry": false
+              },
+              "log": ""
+            }`))
+					})
+				})
+
+				Context("when action error wraps a RetryableError that can be retried", func() {
+					BeforeEach(func() {
+						caller.CallErr = bslcapi.WrapError(fakeapi.NewFakeRetryableError("fake-error", true), "fake-wrap-message")
+					})
+
+					It("returns error with ok_to_retry set to true", func() {
+						response := dispatcher.Dispatch([]byte(`{"method":"fake-action","arguments":["fake-arg"]}`))
+						Expect(response).To(MatchJSON(`{
+							"result": null,
+              "error": {
+                "type":"Bosh::Clouds::CloudError",
+                "message":"fake-wrap-message: fake-error",
+                "ok_to_retry": true
+              },
+              "log": ""
+            }`))
+					})
+				})
+
 				Context("when action error is neither CloudError or RetryableError", func() {
 					BeforeEach(func() {
 						caller.CallErr = errors.New("fake-run-err")
@@ -16,12 +16,66 @@ type RetryableError interface {
 	CanRetry() bool
 }
 
+// FindError returns the first error matching match in the chain of causes
+// of err, starting with err itself. Causes are followed through errors that
+// expose them, such as the ones returned by WrapError.
+func FindError(err error, match func(error) bool) (error, bool) {
+	for err != nil {
+		if match(err) {
+			return err, true
+		}
+
+		causer, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			break
+		}
+
+		err = causer.Cause()
+	}
+
+	return nil, false
+}
+
+// -
+type wrappedError struct {
+	message string
+	cause   error
+}
+
+// WrapError reads like bosherr.WrapError but keeps cause reachable through
+// Cause, so that FindError still sees the typed error behind the message.
+// bosherr does not expose the errors it wraps.
+func WrapError(cause error, message string) error {
+	return wrappedError{message: message, cause: cause}
+}
+
+func WrapErrorf(cause error, message string, args ...interface{}) error {
+	return WrapError(cause, fmt.Sprintf(message, args...))
+}
+
+func (e wrappedError) Error() string { return fmt.Sprintf("%s: %s", e.message, e.cause.Error()) }
+func (e wrappedError) Cause() error  { return e.cause }
+
 // -
 type NotSupportedError struct{}
 
 func (e NotSupportedError) Type() string  { return "Bosh::Clouds::NotSupported" }
 func (e NotSupportedError) Error() string { return "Not supported" }
 
+// -
+type cloudError struct {
+	message string
+}
+
+func NewCloudError(message string) cloudError {
+	return cloudError{message: message}
+}
+
+func (e cloudError) Type() string  { return "Bosh::Clouds::CloudError" }
+func (e cloudError) Error() string { return e.message }
+
 // -
 type vmNotFoundError struct {
 	vmID string
@@ -35,11 +89,26 @@ func (e vmNotFoundError) Type() string  { return "Bosh::Clouds::VMNotFound" }
 func (e vmNotFoundError) Error() string { return fmt.Sprintf("VM '%s' not found", e.vmID) }
 
 // -
-type VMCreationFailedError struct{}
+type VMCreationFailedError struct {
+	reason   string
+	canRetry bool
+}
+
+func NewVMCreationFailedError(reason string, canRetry bool) VMCreationFailedError {
+	return VMCreationFailedError{reason: reason, canRetry: canRetry}
+}
+
+func (e VMCreationFailedError) Type() string { return "Bosh::Clouds::VMCreationFailed" }
+
+func (e VMCreationFailedError) Error() string {
+	if e.reason == "" {
+		return "VM failed to create"
+	}
+
+	return fmt.Sprintf("VM failed to create: %s", e.reason)
+}
 
-func (e VMCreationFailedError) Type() string   { return "Bosh::Clouds::VMCreationFailed" }
-func (e VMCreationFailedError) Error() string  { return "VM failed to create" }
-func (e VMCreationFailedError) CanRetry() bool { return false }
+func (e VMCreationFailedError) CanRetry() bool { return e.canRetry }
 
 // -
 type NoDiskSpaceError struct{}
@@ -1,6 +1,7 @@
 package test_helpers
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	fakesslclient "github.com/maximilien/softlayer-go/client/fakes"
+	slservices "github.com/maximilien/softlayer-go/services"
 )
 
 func ReadJsonTestFixtures(workingDir, packageName, fileName string) ([]byte, error) {
@@ -33,3 +35,68 @@ func SetTestFixturesForFakeSoftLayerClient(fakeSoftLayerClient *fakesslclient.Fa
 		fakeSoftLayerClient.DoRawHttpRequestResponses = append(fakeSoftLayerClient.DoRawHttpRequestResponses, fileContents)
 	}
 }
+
+type RecordedRequest struct {
+	Path        string
+	ObjectMask  []string
+	RequestType string
+	Body        []byte
+}
+
+// RecordingSoftLayerClient records every request made through it, including
+// those made by the services it hands out, and answers them with the fake
+// client it wraps
+type RecordingSoftLayerClient struct {
+	*fakesslclient.FakeSoftLayerClient
+
+	Requests []RecordedRequest
+}
+
+func NewRecordingSoftLayerClient(fakeSoftLayerClient *fakesslclient.FakeSoftLayerClient) *RecordingSoftLayerClient {
+	client := &RecordingSoftLayerClient{FakeSoftLayerClient: fakeSoftLayerClient}
+
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Account"] = slservices.NewSoftLayer_Account_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Virtual_Guest"] = slservices.NewSoftLayer_Virtual_Guest_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Virtual_Disk_Image"] = slservices.NewSoftLayer_Virtual_Disk_Image_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Security_Ssh_Key"] = slservices.NewSoftLayer_Security_Ssh_Key_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Network_Storage"] = slservices.NewSoftLayer_Network_Storage_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Product_Order"] = slservices.NewSoftLayer_Product_Order_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Product_Package"] = slservices.NewSoftLayer_Product_Package_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Billing_Item_Cancellation_Request"] = slservices.NewSoftLayer_Billing_Item_Cancellation_Request_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Virtual_Guest_Block_Device_Template_Group"] = slservices.NewSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service(client)
+	fakeSoftLayerClient.SoftLayerServices["SoftLayer_Hardware"] = slservices.NewSoftLayer_Hardware_Service(client)
+
+	return client
+}
+
+func (c *RecordingSoftLayerClient) DoRawHttpRequestWithObjectMask(path string, masks []string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
+	c.record(path, requestType, requestBody)
+	c.Requests[len(c.Requests)-1].ObjectMask = masks
+
+	return c.FakeSoftLayerClient.DoRawHttpRequestWithObjectMask(path, masks, requestType, requestBody)
+}
+
+func (c *RecordingSoftLayerClient) DoRawHttpRequest(path string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
+	c.record(path, requestType, requestBody)
+	return c.FakeSoftLayerClient.DoRawHttpRequest(path, requestType, requestBody)
+}
+
+// RequestTo returns the body of the last request made to path
+func (c *RecordingSoftLayerClient) RequestTo(path string) ([]byte, bool) {
+	for i := len(c.Requests) - 1; i >= 0; i-- {
+		if c.Requests[i].Path == path {
+			return c.Requests[i].Body, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *RecordingSoftLayerClient) record(path string, requestType string, requestBody *bytes.Buffer) {
+	request := RecordedRequest{Path: path, RequestType: requestType}
+	if requestBody != nil {
+		request.Body = append([]byte{}, requestBody.Bytes()...)
+	}
+
+	c.Requests = append(c.Requests, request)
+}
@@ -7,6 +7,7 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-agent/system"
 
 	bslcaction "github.com/maximilien/bosh-softlayer-cpi/action"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 type Config struct {
@@ -18,6 +19,8 @@ type Config struct {
 type SoftLayerConfig struct {
 	Username string `json:"username"`
 	ApiKey   string `json:"apiKey"`
+
+	Retry bslcommon.RetryOptions `json:"retry"`
 }
 
 func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
@@ -12,6 +12,7 @@ import (
 	bslcaction "github.com/maximilien/bosh-softlayer-cpi/action"
 	bslcdisp "github.com/maximilien/bosh-softlayer-cpi/api/dispatcher"
 	bslctrans "github.com/maximilien/bosh-softlayer-cpi/api/transport"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 const mainLogTag = "main"
@@ -55,7 +56,11 @@ func basicDeps() (boshlog.Logger, boshsys.FileSystem, boshsys.CmdRunner) {
 }
 
 func buildDispatcher(config Config, logger boshlog.Logger, fs boshsys.FileSystem, cmdRunner boshsys.CmdRunner) bslcdisp.Dispatcher {
-	softLayerClient := slclient.NewSoftLayerClient(config.SoftLayer.Username, config.SoftLayer.ApiKey)
+	softLayerClient := bslcommon.NewRetryingClient(
+		slclient.NewSoftLayerClient(config.SoftLayer.Username, config.SoftLayer.ApiKey),
+		config.SoftLayer.Retry.WithDefaults(bslcommon.DefaultRetryOptions),
+		logger,
+	)
 
 	actionFactory := bslcaction.NewConcreteFactory(
 		softLayerClient,
@@ -0,0 +1,52 @@
+package baremetal
+
+// BareMetalCloudProperties describes the bare-metal server to order
+type BareMetalCloudProperties struct {
+	// Memory is in GB
+	Memory    int `json:"memory,omitempty"`
+	Processor int `json:"processor,omitempty"`
+
+	// DiskSize is in GB
+	DiskSize int `json:"diskSize,omitempty"`
+
+	Hostname   string `json:"hostname,omitempty"`
+	Domain     string `json:"domain,omitempty"`
+	Os         string `json:"os,omitempty"`
+	Datacenter string `json:"datacenter,omitempty"`
+}
+
+// MergeDefaults returns the cloud properties with every unset value taken
+// from defaults
+func (p BareMetalCloudProperties) MergeDefaults(defaults BareMetalCloudProperties) BareMetalCloudProperties {
+	merged := p
+
+	if merged.Memory == 0 {
+		merged.Memory = defaults.Memory
+	}
+
+	if merged.Processor == 0 {
+		merged.Processor = defaults.Processor
+	}
+
+	if merged.DiskSize == 0 {
+		merged.DiskSize = defaults.DiskSize
+	}
+
+	if merged.Hostname == "" {
+		merged.Hostname = defaults.Hostname
+	}
+
+	if merged.Domain == "" {
+		merged.Domain = defaults.Domain
+	}
+
+	if merged.Os == "" {
+		merged.Os = defaults.Os
+	}
+
+	if merged.Datacenter == "" {
+		merged.Datacenter = defaults.Datacenter
+	}
+
+	return merged
+}
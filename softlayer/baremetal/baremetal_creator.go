@@ -1,10 +1,16 @@
 package baremetal
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	datatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 const bmCreatorLogTag = "BaremetalCreator"
@@ -24,68 +30,214 @@ func NewBaremetalCreator(
 	}
 }
 
-func (c BaremetalCreator) Create(memory int, processor int, disksize int, host string, domain string, ostype string, datacenter string) (datatypes.SoftLayer_Hardware, error) {
-	c.logger.Debug(bmCreatorLogTag, "Creating baremetal %s.%s of %s with memory: %d, processors: %d, disk size '%d'", host, domain, ostype, memory, processor, disksize)
+func (c BaremetalCreator) Create(cloudProps BareMetalCloudProperties) (datatypes.SoftLayer_Hardware, error) {
+	c.logger.Debug(bmCreatorLogTag, "Creating baremetal %s.%s of %s with memory: %d, processors: %d, disk size '%d'", cloudProps.Hostname, cloudProps.Domain, cloudProps.Os, cloudProps.Memory, cloudProps.Processor, cloudProps.DiskSize)
 
-	err := c.validate_arguments(memory, processor, disksize, host, domain, ostype, datacenter)
+	err := c.validate_arguments(cloudProps)
 	if err != nil {
 		return datatypes.SoftLayer_Hardware{}, err
 	}
 
-	service, err := c.client.GetSoftLayer_Hardware_Service()
+	template := hardwareTemplate{
+		SoftLayer_Hardware_Template: datatypes.SoftLayer_Hardware_Template{
+			Hostname:                     cloudProps.Hostname,
+			Domain:                       cloudProps.Domain,
+			ProcessorCoreAmount:          cloudProps.Processor,
+			MemoryCapacity:               cloudProps.Memory,
+			HourlyBillingFlag:            true,
+			OperatingSystemReferenceCode: cloudProps.Os,
+
+			Datacenter: &datatypes.Datacenter{
+				Name: cloudProps.Datacenter,
+			},
+		},
+
+		HardDrives: []hardDrive{
+			{Capacity: cloudProps.DiskSize},
+		},
+	}
+
+	baremetal, err := c.createHardware(template)
 	if err != nil {
-		return datatypes.SoftLayer_Hardware{}, bosherr.WrapError(err, "Get hardware service error")
+		return datatypes.SoftLayer_Hardware{}, bosherr.WrapError(err, "Create baremetal error")
 	}
 
-	template := datatypes.SoftLayer_Hardware_Template{
-		Hostname:                     host,
-		Domain:                       domain,
-		ProcessorCoreAmount:          processor,
-		MemoryCapacity:               memory,
-		HourlyBillingFlag:            true,
-		OperatingSystemReferenceCode: ostype,
+	return baremetal, nil
+}
 
-		Datacenter: &datatypes.Datacenter{
-			Name: datacenter,
-		},
+// hardwareTemplate extends the softlayer-go template so that the size of the
+// hard drive can be ordered
+type hardwareTemplate struct {
+	datatypes.SoftLayer_Hardware_Template
+
+	HardDrives []hardDrive `json:"hardDrives,omitempty"`
+}
+
+type hardDrive struct {
+	Capacity int `json:"capacity"`
+}
+
+func (c BaremetalCreator) createHardware(template hardwareTemplate) (datatypes.SoftLayer_Hardware, error) {
+	parameters := map[string]interface{}{
+		"parameters": []hardwareTemplate{template},
 	}
 
-	baremetal, err := service.CreateObject(template)
+	requestBody, err := json.Marshal(parameters)
 	if err != nil {
-		return datatypes.SoftLayer_Hardware{}, bosherr.WrapError(err, "Create baremetal error")
+		return datatypes.SoftLayer_Hardware{}, bosherr.WrapError(err, "Marshalling baremetal template")
+	}
+
+	response, err := c.client.DoRawHttpRequest("SoftLayer_Hardware.json", "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return datatypes.SoftLayer_Hardware{}, err
+	}
+
+	err = c.client.CheckForHttpResponseErrors(response)
+	if err != nil {
+		return datatypes.SoftLayer_Hardware{}, err
+	}
+
+	baremetal := datatypes.SoftLayer_Hardware{}
+	err = json.Unmarshal(response, &baremetal)
+	if err != nil {
+		return datatypes.SoftLayer_Hardware{}, bosherr.WrapError(err, "Unmarshalling baremetal")
 	}
 
 	return baremetal, nil
 }
 
-func (c BaremetalCreator) validate_arguments(memory int, processor int, disksize int, host string, domain string, ostype string, datacenter string) error {
+// Cancel cancels the billing item of the server right away so that an order
+// which will not be used stops being billed. A server still being
+// provisioned may not have a billing item yet, so it is waited for within
+// waitOptions. A server that no longer exists is treated as cancelled.
+func (c BaremetalCreator) Cancel(id string, waitOptions bslcommon.WaitOptions) error {
+	c.logger.Debug(bmCreatorLogTag, "Cancelling baremetal %s", id)
+
+	var (
+		hardware billedHardware
+		exists   bool
+	)
+
+	billed, err := waitOptions.Poll(func() (bool, error) {
+		var err error
+		hardware, exists, err = c.findBillingItem(id)
+		if err != nil {
+			return false, err
+		}
+
+		return !exists || hardware.BillingItem.Id != 0, nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Get baremetal billing item error")
+	}
+
+	if !exists {
+		c.logger.Debug(bmCreatorLogTag, "Baremetal %s no longer exists", id)
+		return nil
+	}
+
+	if !billed {
+		return bosherr.Errorf("Baremetal '%s' did not get a billing item to cancel within %s", id, waitOptions.Timeout)
+	}
+
+	service, err := c.client.GetSoftLayer_Billing_Item_Cancellation_Request_Service()
+	if err != nil {
+		return bosherr.WrapError(err, "Get billing item cancellation request service error")
+	}
+
+	request := datatypes.SoftLayer_Billing_Item_Cancellation_Request{
+		ComplexType: "SoftLayer_Billing_Item_Cancellation_Request",
+		AccountId:   hardware.AccountId,
+		Items: []datatypes.SoftLayer_Billing_Item_Cancellation_Request_Item{
+			{
+				BillingItemId:             hardware.BillingItem.Id,
+				ImmediateCancellationFlag: true,
+			},
+		},
+	}
+
+	cancellation, err := service.CreateObject(request)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Cancel baremetal billing item '%d' error", hardware.BillingItem.Id)
+	}
+
+	// A fault unmarshals to an empty cancellation request
+	if cancellation.Id == 0 {
+		return bosherr.Errorf("Failed to cancel baremetal billing item '%d', SoftLayer did not create a cancellation request", hardware.BillingItem.Id)
+	}
+
+	return nil
+}
+
+type billedHardware struct {
+	Id          int `json:"id"`
+	AccountId   int `json:"accountId"`
+	BillingItem struct {
+		Id int `json:"id"`
+	} `json:"billingItem"`
+}
+
+// findBillingItem tells whether the server exists, which it does not when
+// SoftLayer answers with an empty object or a not found fault, and returns
+// its billing item, which has no ID while the server is not billed yet
+func (c BaremetalCreator) findBillingItem(id string) (billedHardware, bool, error) {
+	objectMask := []string{"id", "accountId", "billingItem.id"}
+
+	client := bslcommon.NewFaultDetectingClient(c.client)
+
+	response, err := client.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Hardware/%s.json", id), objectMask, "GET", new(bytes.Buffer))
+	if err != nil {
+		if _, ok := err.(bslcommon.ObjectNotFoundError); ok {
+			return billedHardware{}, false, nil
+		}
+
+		return billedHardware{}, false, err
+	}
+
+	hardware := billedHardware{}
+	err = json.Unmarshal(response, &hardware)
+	if err != nil {
+		return billedHardware{}, false, bosherr.WrapError(err, "Unmarshalling baremetal billing item")
+	}
+
+	return hardware, hardware.Id != 0, nil
+}
+
+// validate_arguments returns an InvalidCloudPropertiesError listing every
+// value that is missing or out of range
+func (c BaremetalCreator) validate_arguments(cloudProps BareMetalCloudProperties) error {
+	problems, requiredTemplate := []string{}, "%s is required and cannot be empty"
+
+	if cloudProps.Memory <= 0 {
+		problems = append(problems, fmt.Sprintf("memory must be a positive number of GB, it is set to be %d", cloudProps.Memory))
+	}
 
-	if memory <= 0 {
-		return bosherr.Errorf("memory can not be negative: %d", memory)
+	if cloudProps.Processor <= 0 {
+		problems = append(problems, fmt.Sprintf("processor must be a positive number of cores, it is set to be %d", cloudProps.Processor))
 	}
 
-	if processor <= 0 {
-		return bosherr.Errorf("processor can not be negative: %d", processor)
+	if cloudProps.DiskSize <= 0 {
+		problems = append(problems, fmt.Sprintf("diskSize must be a positive number of GB, it is set to be %d", cloudProps.DiskSize))
 	}
 
-	if disksize <= 0 {
-		return bosherr.Errorf("disk size can not be negative: %d", disksize)
+	if cloudProps.Hostname == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "hostname"))
 	}
 
-	if host == "" {
-		return bosherr.Error("host can not be empty.")
+	if cloudProps.Domain == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "domain"))
 	}
 
-	if domain == "" {
-		return bosherr.Error("domain can not be empty.")
+	if cloudProps.Os == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "os: the operating system reference code"))
 	}
 
-	if ostype == "" {
-		return bosherr.Error("os type can not be empty.")
+	if cloudProps.Datacenter == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "datacenter"))
 	}
 
-	if datacenter == "" {
-		return bosherr.Error("data center can not be empty.")
+	if len(problems) > 0 {
+		return bslcommon.InvalidCloudPropertiesError{Problems: problems}
 	}
 
 	return nil
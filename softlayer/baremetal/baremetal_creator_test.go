@@ -1,12 +1,16 @@
 package baremetal_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	common "github.com/maximilien/bosh-softlayer-cpi/common"
 	bm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
 	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
 )
 
@@ -29,61 +33,160 @@ var _ = Describe("BaremetalCreator", func() {
 	})
 
 	Describe("Create", func() {
+		var cloudProps bm.BareMetalCloudProperties
+
+		BeforeEach(func() {
+			cloudProps = bm.BareMetalCloudProperties{
+				Memory:     2,
+				Processor:  1,
+				DiskSize:   10,
+				Hostname:   "fake-host",
+				Domain:     "fake-domain",
+				Os:         "fake-os",
+				Datacenter: "fake-data-center",
+			}
+		})
+
 		Context("succeeded", func() {
 			It("returns a new Softlayer Hardware without an error", func() {
-				baremetal, err := creator.Create(2, 1, 10, "fake-host", "fake-domain", "fake-os", "fake-data-center")
+				baremetal, err := creator.Create(cloudProps)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(baremetal.GlobalIdentifier).To(Equal("fake-id"))
 				Expect(baremetal.BareMetalInstanceFlag).To(Equal(0))
 				Expect(baremetal.ProvisionDate).To(BeNil())
 				Expect(baremetal.PrimaryIpAddress).To(Equal(""))
 			})
+
+			It("orders a hard drive of the disk size", func() {
+				recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+				creator = bm.NewBaremetalCreator(recordingClient, logger)
+
+				_, err := creator.Create(cloudProps)
+				Expect(err).ToNot(HaveOccurred())
+
+				body, found := recordingClient.RequestTo("SoftLayer_Hardware.json")
+				Expect(found).To(BeTrue())
+				Expect(string(body)).To(ContainSubstring(`"hardDrives":[{"capacity":10}]`))
+			})
 		})
 
 		Context("failed", func() {
 			Context("due to incorrect arguments", func() {
 				It("returns an error when memory is incorrect", func() {
-					_, err := creator.Create(-2, 1, 10, "fake-host", "fake-domain", "fake-os", "fake-data-center")
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("memory can not be negative: -2"))
+					cloudProps.Memory = -2
+
+					_, err := creator.Create(cloudProps)
+					Expect(err).To(Equal(bslcommon.InvalidCloudPropertiesError{Problems: []string{
+						"memory must be a positive number of GB, it is set to be -2",
+					}}))
 				})
 
 				It("returns an error when processor is incorrect", func() {
-					_, err := creator.Create(2, -1, 10, "fake-host", "fake-domain", "fake-os", "fake-data-center")
+					cloudProps.Processor = 0
+
+					_, err := creator.Create(cloudProps)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("processor can not be negative: -1"))
+					Expect(err.Error()).To(ContainSubstring("processor must be a positive number of cores, it is set to be 0"))
 				})
 
 				It("returns an error when disksize is incorrect", func() {
-					_, err := creator.Create(2, 1, -10, "fake-host", "fake-domain", "fake-os", "fake-data-center")
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("disk size can not be negative: -10"))
-				})
+					cloudProps.DiskSize = -10
 
-				It("returns an error when host is incorrect", func() {
-					_, err := creator.Create(2, 1, 10, "", "fake-domain", "fake-os", "fake-data-center")
+					_, err := creator.Create(cloudProps)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("host can not be empty."))
+					Expect(err.Error()).To(ContainSubstring("diskSize must be a positive number of GB, it is set to be -10"))
 				})
 
-				It("returns an error when domain is incorrect", func() {
-					_, err := creator.Create(2, 1, 10, "fake-host", "", "fake-os", "fake-data-center")
+				It("lists every missing value", func() {
+					_, err := creator.Create(bm.BareMetalCloudProperties{Memory: 2, Processor: 1, DiskSize: 10})
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("domain can not be empty."))
+					Expect(err.Error()).To(Equal("Invalid cloud properties:\n" +
+						"* hostname is required and cannot be empty\n" +
+						"* domain is required and cannot be empty\n" +
+						"* os: the operating system reference code is required and cannot be empty\n" +
+						"* datacenter is required and cannot be empty"))
 				})
+			})
+		})
+	})
 
-				It("returns an error when ostype is incorrect", func() {
-					_, err := creator.Create(2, 1, 10, "fake-host", "fake-domain", "", "fake-data-center")
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("os type can not be empty."))
-				})
+	Describe("Cancel", func() {
+		var (
+			sleeper     *util.RecordingNoopSleeper
+			waitOptions bslcommon.WaitOptions
+		)
 
-				It("returns an error when datacenter is incorrect", func() {
-					_, err := creator.Create(2, 1, 10, "fake-host", "fake-domain", "fake-os", "")
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("data center can not be empty."))
-				})
-			})
+		BeforeEach(func() {
+			softLayerClient.DoRawHttpRequestResponse = nil
+
+			sleeper = util.NewRecordingNoopSleeper()
+			waitOptions = bslcommon.WaitOptions{Timeout: 3 * time.Minute, PollingInterval: 1 * time.Minute, Sleeper: sleeper}
+		})
+
+		It("cancels the billing item of the server right away", func() {
+			fileNames := []string{
+				"SoftLayer_Hardware_Service_getObject.json",
+				"SoftLayer_Billing_Item_Cancellation_Request_Service_createObject.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+			creator = bm.NewBaremetalCreator(recordingClient, logger)
+
+			err := creator.Cancel("fake-id", waitOptions)
+			Expect(err).ToNot(HaveOccurred())
+
+			body, found := recordingClient.RequestTo("SoftLayer_Billing_Item_Cancellation_Request/createObject.json")
+			Expect(found).To(BeTrue())
+			Expect(body).To(MatchJSON(`{"parameters": [{
+				"complexType": "SoftLayer_Billing_Item_Cancellation_Request",
+				"accountId": 1,
+				"id": 0,
+				"ticketId": 0,
+				"items": [{"billingItemId": 1234567, "immediateCancellationFlag": true}]
+			}]}`))
+
+			_, found = recordingClient.RequestTo("SoftLayer_Hardware/fake-id.json")
+			Expect(found).To(BeTrue())
+			Expect(sleeper.SleptTimes()).To(BeEmpty())
+		})
+
+		It("waits for a server still being provisioned to get its billing item", func() {
+			fileNames := []string{
+				"SoftLayer_Hardware_Service_createObject.json",
+				"SoftLayer_Hardware_Service_getObject.json",
+				"SoftLayer_Billing_Item_Cancellation_Request_Service_createObject.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			err := creator.Cancel("fake-id", waitOptions)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sleeper.SleptTimes()).To(Equal([]time.Duration{1 * time.Minute}))
+			Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(3))
+		})
+
+		It("returns an error when the server gets no billing item in time", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_createObject.json")
+
+			err := creator.Cancel("fake-id", waitOptions)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Baremetal 'fake-id' did not get a billing item to cancel within 3m0s"))
+		})
+
+		It("treats a server that no longer exists as cancelled", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_getObject_None_Exist.json")
+
+			err := creator.Cancel("fake-id", waitOptions)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when SoftLayer does not create the cancellation request", func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Hardware_Service_getObject.json"})
+			softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte(`{"error": "fake-error", "code": "SoftLayer_Exception_Public"}`))
+
+			err := creator.Cancel("fake-id", waitOptions)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Failed to cancel baremetal billing item '1234567'"))
 		})
 	})
 })
@@ -1,12 +1,50 @@
 package baremetal
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	datatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
+// BaremetalServer identifies a provisioned bare-metal server. The root
+// password is not included; RootCredentials names the SoftLayer record
+// holding it instead.
+type BaremetalServer struct {
+	Id               int    `json:"id"`
+	GlobalIdentifier string `json:"global_identifier"`
+	Hostname         string `json:"hostname"`
+	Domain           string `json:"domain"`
+
+	PrimaryIpAddress        string `json:"primary_ip_address"`
+	PrimaryBackendIpAddress string `json:"primary_backend_ip_address"`
+
+	RootCredentials string `json:"root_credentials"`
+}
+
+type hardwareWithPasswords struct {
+	Id               int    `json:"id"`
+	GlobalIdentifier string `json:"globalIdentifier"`
+	Hostname         string `json:"hostname"`
+	Domain           string `json:"domain"`
+
+	PrimaryIpAddress        string `json:"primaryIpAddress"`
+	PrimaryBackendIpAddress string `json:"primaryBackendIpAddress"`
+
+	OperatingSystem struct {
+		Passwords []struct {
+			Id       int    `json:"id"`
+			Username string `json:"username"`
+		} `json:"passwords"`
+	} `json:"operatingSystem"`
+}
+
 type BaremetalFinder struct {
 	client sl.Client
 	logger boshlog.Logger
@@ -33,3 +71,75 @@ func (f BaremetalFinder) Find(id string) (datatypes.SoftLayer_Hardware, error) {
 
 	return baremetal, nil
 }
+
+// WaitForProvisioning polls the server until SoftLayer reports its provision
+// date. It returns false when waitOptions.Timeout elapses first.
+func (f BaremetalFinder) WaitForProvisioning(id string, waitOptions bslcommon.WaitOptions) (bool, error) {
+	return waitOptions.Poll(func() (bool, error) {
+		response, err := f.client.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Hardware/%s.json", id), []string{"id", "provisionDate"}, "GET", new(bytes.Buffer))
+		if err != nil {
+			return false, bosherr.WrapError(err, "Get baremetal error")
+		}
+
+		err = f.client.CheckForHttpResponseErrors(response)
+		if err != nil {
+			return false, bosherr.WrapError(err, "Get baremetal error")
+		}
+
+		baremetal := datatypes.SoftLayer_Hardware{}
+		err = json.Unmarshal(response, &baremetal)
+		if err != nil {
+			return false, bosherr.WrapError(err, "Unmarshalling baremetal")
+		}
+
+		return baremetal.ProvisionDate != nil, nil
+	})
+}
+
+// Describe returns the identity, IPs and root credentials reference of a
+// provisioned server
+func (f BaremetalFinder) Describe(id string) (BaremetalServer, error) {
+	objectMask := []string{
+		"id",
+		"globalIdentifier",
+		"hostname",
+		"domain",
+		"primaryIpAddress",
+		"primaryBackendIpAddress",
+		"operatingSystem.passwords.id",
+		"operatingSystem.passwords.username",
+	}
+
+	response, err := f.client.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Hardware/%s.json", id), objectMask, "GET", new(bytes.Buffer))
+	if err != nil {
+		return BaremetalServer{}, bosherr.WrapError(err, "Get baremetal error")
+	}
+
+	hardware := hardwareWithPasswords{}
+	err = json.Unmarshal(response, &hardware)
+	if err != nil {
+		return BaremetalServer{}, bosherr.WrapError(err, "Unmarshalling baremetal")
+	}
+
+	if hardware.GlobalIdentifier == "" {
+		return BaremetalServer{}, bosherr.Errorf("can not find the baremetal server with id: %s.", id)
+	}
+
+	server := BaremetalServer{
+		Id:               hardware.Id,
+		GlobalIdentifier: hardware.GlobalIdentifier,
+		Hostname:         hardware.Hostname,
+		Domain:           hardware.Domain,
+
+		PrimaryIpAddress:        hardware.PrimaryIpAddress,
+		PrimaryBackendIpAddress: hardware.PrimaryBackendIpAddress,
+	}
+
+	for _, password := range hardware.OperatingSystem.Passwords {
+		if password.Username == "root" {
+			server.RootCredentials = fmt.Sprintf("SoftLayer_Software_Component_Password/%d", password.Id)
+		}
+	}
+
+	return server, nil
+}
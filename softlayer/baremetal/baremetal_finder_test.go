@@ -1,12 +1,16 @@
 package baremetal_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	common "github.com/maximilien/bosh-softlayer-cpi/common"
 	bm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
 	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
 )
 
@@ -56,4 +60,73 @@ var _ = Describe("BaremetalFinder", func() {
 
 		})
 	})
+
+	Describe("WaitForProvisioning", func() {
+		var (
+			sleeper     *util.RecordingNoopSleeper
+			waitOptions bslcommon.WaitOptions
+		)
+
+		BeforeEach(func() {
+			sleeper = util.NewRecordingNoopSleeper()
+			waitOptions = bslcommon.WaitOptions{Timeout: 3 * time.Minute, PollingInterval: 1 * time.Minute, Sleeper: sleeper}
+		})
+
+		It("polls until the server has a provision date", func() {
+			fileNames := []string{
+				"SoftLayer_Hardware_Service_createObject.json",
+				"SoftLayer_Hardware_Service_getObject.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+			finder = bm.NewBaremetalFinder(recordingClient, logger)
+
+			provisioned, err := finder.WaitForProvisioning("fake-id", waitOptions)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(provisioned).To(BeTrue())
+			Expect(sleeper.SleptTimes()).To(Equal([]time.Duration{1 * time.Minute}))
+
+			Expect(recordingClient.Requests).To(HaveLen(2))
+			for _, request := range recordingClient.Requests {
+				Expect(request.Path).To(Equal("SoftLayer_Hardware/fake-id.json"))
+				Expect(request.ObjectMask).To(Equal([]string{"id", "provisionDate"}))
+			}
+		})
+
+		It("returns false once the timeout elapses", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_createObject.json")
+
+			provisioned, err := finder.WaitForProvisioning("fake-id", waitOptions)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(provisioned).To(BeFalse())
+			Expect(sleeper.SleptTimes()).To(HaveLen(3))
+		})
+	})
+
+	Describe("Describe", func() {
+		It("returns the identity, IPs and a reference to the root credentials", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_getObject.json")
+
+			server, err := finder.Describe("fake-id")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server).To(Equal(bm.BaremetalServer{
+				Id:                      1,
+				GlobalIdentifier:        "fake-id",
+				Hostname:                "fake-name",
+				Domain:                  "fake.com",
+				PrimaryIpAddress:        "1.1.1.1",
+				PrimaryBackendIpAddress: "10.1.1.1",
+				RootCredentials:         "SoftLayer_Software_Component_Password/1234",
+			}))
+		})
+
+		It("returns an error when the server cannot be found", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_getObject_None_Exist.json")
+
+			_, err := finder.Describe("none-exist-id")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("can not find the baremetal server with id: none-exist-id."))
+		})
+	})
 })
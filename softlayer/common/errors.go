@@ -0,0 +1,18 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InvalidCloudPropertiesError is returned when cloud properties cannot be
+// used to order a VM, be it a virtual guest or a bare-metal server
+type InvalidCloudPropertiesError struct {
+	Problems []string
+}
+
+func (e InvalidCloudPropertiesError) Type() string { return "Bosh::Clouds::VMCreationFailed" }
+
+func (e InvalidCloudPropertiesError) Error() string {
+	return fmt.Sprintf("Invalid cloud properties:\n* %s", strings.Join(e.Problems, "\n* "))
+}
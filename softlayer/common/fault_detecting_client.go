@@ -0,0 +1,52 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+
+	sl "github.com/maximilien/softlayer-go/softlayer"
+)
+
+// ObjectNotFoundError is returned by FaultDetectingClient when SoftLayer
+// answers that the requested object does not exist
+type ObjectNotFoundError struct {
+	Path string
+}
+
+func (e ObjectNotFoundError) Error() string {
+	return fmt.Sprintf("SoftLayer object '%s' does not exist", e.Path)
+}
+
+// FaultDetectingClient wraps a SoftLayer client so that every request,
+// including those made by the services it hands out, fails when SoftLayer
+// answers with a fault. The services of softlayer-go would otherwise
+// unmarshal a fault into an empty object.
+type FaultDetectingClient struct {
+	hookedClient
+}
+
+func NewFaultDetectingClient(client sl.Client) FaultDetectingClient {
+	return FaultDetectingClient{
+		hookedClient: newHookedClient(client, func(path string, requestType string, requestBody *bytes.Buffer, send func(*bytes.Buffer) ([]byte, error)) ([]byte, error) {
+			response, err := send(requestBody)
+			return detectFault(client, path, response, err)
+		}),
+	}
+}
+
+func detectFault(client sl.Client, path string, response []byte, err error) ([]byte, error) {
+	if err != nil {
+		return response, err
+	}
+
+	if IsNotFoundFault(response) {
+		return nil, ObjectNotFoundError{Path: path}
+	}
+
+	err = client.CheckForHttpResponseErrors(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
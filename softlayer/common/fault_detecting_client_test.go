@@ -0,0 +1,75 @@
+package common_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("FaultDetectingClient", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		client          FaultDetectingClient
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		client = NewFaultDetectingClient(softLayerClient)
+	})
+
+	Describe("DoRawHttpRequest", func() {
+		It("returns the response when SoftLayer does not answer with a fault", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("true")
+
+			response, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response).To(Equal([]byte("true")))
+		})
+
+		It("returns an ObjectNotFoundError when SoftLayer answers with a not found fault", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "Unable to find object with id of '1234'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+
+			_, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).To(Equal(ObjectNotFoundError{Path: "fake-path"}))
+		})
+
+		It("returns error when SoftLayer answers with any other fault", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "fake-error", "code": "SoftLayer_Exception_Public"}`)
+			softLayerClient.CheckForHttpResponseError = errors.New("fake-response-err")
+
+			_, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).To(MatchError("fake-response-err"))
+		})
+	})
+
+	Describe("services", func() {
+		It("send their requests through the fault detecting client", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "Unable to find object with id of '1234567'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+
+			virtualGuestService, err := client.GetSoftLayer_Virtual_Guest_Service()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = virtualGuestService.GetObject(1234567)
+			Expect(err).To(BeAssignableToTypeOf(ObjectNotFoundError{}))
+		})
+
+		It("still return objects when there is no fault", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getObject.json")
+
+			virtualGuestService, err := client.GetSoftLayer_Virtual_Guest_Service()
+			Expect(err).ToNot(HaveOccurred())
+
+			virtualGuest, err := virtualGuestService.GetObject(1234567)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(virtualGuest.Id).ToNot(BeZero())
+		})
+	})
+})
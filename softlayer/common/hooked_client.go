@@ -0,0 +1,105 @@
+package common
+
+import (
+	"bytes"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	services "github.com/maximilien/softlayer-go/services"
+	sl "github.com/maximilien/softlayer-go/softlayer"
+)
+
+// requestHook sends a request with send, which it may call again to resend
+// the body, and returns what the caller of the client gets back
+type requestHook func(path string, requestType string, requestBody *bytes.Buffer, send func(*bytes.Buffer) ([]byte, error)) ([]byte, error)
+
+// hookedClient wraps a SoftLayer client so that every request, including
+// those made by the services it hands out, goes through hook
+type hookedClient struct {
+	sl.Client
+
+	hook requestHook
+}
+
+func newHookedClient(client sl.Client, hook requestHook) hookedClient {
+	return hookedClient{Client: client, hook: hook}
+}
+
+func (c hookedClient) GetService(name string) (sl.Service, error) {
+	switch name {
+	case "SoftLayer_Account":
+		return c.GetSoftLayer_Account_Service()
+	case "SoftLayer_Virtual_Guest":
+		return c.GetSoftLayer_Virtual_Guest_Service()
+	case "SoftLayer_Virtual_Disk_Image":
+		return c.GetSoftLayer_Virtual_Disk_Image_Service()
+	case "SoftLayer_Security_Ssh_Key":
+		return c.GetSoftLayer_Security_Ssh_Key_Service()
+	case "SoftLayer_Product_Package":
+		return c.GetSoftLayer_Product_Package_Service()
+	case "SoftLayer_Product_Order":
+		return c.GetSoftLayer_Product_Order_Service()
+	case "SoftLayer_Network_Storage":
+		return c.GetSoftLayer_Network_Storage_Service()
+	case "SoftLayer_Billing_Item_Cancellation_Request":
+		return c.GetSoftLayer_Billing_Item_Cancellation_Request_Service()
+	case "SoftLayer_Virtual_Guest_Block_Device_Template_Group":
+		return c.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
+	case "SoftLayer_Hardware":
+		return c.GetSoftLayer_Hardware_Service()
+	}
+
+	return nil, bosherr.Errorf("softlayer-go does not support service '%s'", name)
+}
+
+func (c hookedClient) GetSoftLayer_Account_Service() (sl.SoftLayer_Account_Service, error) {
+	return services.NewSoftLayer_Account_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Virtual_Guest_Service() (sl.SoftLayer_Virtual_Guest_Service, error) {
+	return services.NewSoftLayer_Virtual_Guest_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Virtual_Disk_Image_Service() (sl.SoftLayer_Virtual_Disk_Image_Service, error) {
+	return services.NewSoftLayer_Virtual_Disk_Image_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Security_Ssh_Key_Service() (sl.SoftLayer_Security_Ssh_Key_Service, error) {
+	return services.NewSoftLayer_Security_Ssh_Key_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Product_Package_Service() (sl.SoftLayer_Product_Package_Service, error) {
+	return services.NewSoftLayer_Product_Package_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Product_Order_Service() (sl.SoftLayer_Product_Order_Service, error) {
+	return services.NewSoftLayer_Product_Order_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Network_Storage_Service() (sl.SoftLayer_Network_Storage_Service, error) {
+	return services.NewSoftLayer_Network_Storage_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Billing_Item_Cancellation_Request_Service() (sl.SoftLayer_Billing_Item_Cancellation_Request_Service, error) {
+	return services.NewSoftLayer_Billing_Item_Cancellation_Request_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service() (sl.SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service, error) {
+	return services.NewSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service(c), nil
+}
+
+func (c hookedClient) GetSoftLayer_Hardware_Service() (sl.SoftLayer_Hardware_Service, error) {
+	return services.NewSoftLayer_Hardware_Service(c), nil
+}
+
+func (c hookedClient) DoRawHttpRequest(path string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
+	return c.hook(path, requestType, requestBody, func(body *bytes.Buffer) ([]byte, error) {
+		return c.Client.DoRawHttpRequest(path, requestType, body)
+	})
+}
+
+func (c hookedClient) DoRawHttpRequestWithObjectMask(path string, masks []string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
+	return c.hook(path, requestType, requestBody, func(body *bytes.Buffer) ([]byte, error) {
+		return c.Client.DoRawHttpRequestWithObjectMask(path, masks, requestType, body)
+	})
+}
@@ -0,0 +1,296 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+)
+
+const retryingClientLogTag = "RetryingClient"
+
+// RetryOptions controls how SoftLayer API requests failing with a transient
+// error are retried. The interval doubles after every attempt up to
+// MaxInterval, and each sleep is jittered to spread out concurrent callers.
+type RetryOptions struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+
+	// Defaults to a real sleeper when not set
+	Sleeper util.Sleeper
+}
+
+var DefaultRetryOptions = RetryOptions{MaxAttempts: 5, InitialInterval: 2 * time.Second, MaxInterval: 30 * time.Second}
+
+type retryOptionsJSON struct {
+	MaxAttempts     int
+	InitialInterval string
+	MaxInterval     string
+}
+
+// UnmarshalJSON reads InitialInterval and MaxInterval as duration strings
+// such as "2s" or "1m"
+func (o *RetryOptions) UnmarshalJSON(data []byte) error {
+	var options retryOptionsJSON
+
+	err := json.Unmarshal(data, &options)
+	if err != nil {
+		return err
+	}
+
+	*o = RetryOptions{MaxAttempts: options.MaxAttempts}
+
+	if options.InitialInterval != "" {
+		o.InitialInterval, err = time.ParseDuration(options.InitialInterval)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing InitialInterval '%s'", options.InitialInterval)
+		}
+	}
+
+	if options.MaxInterval != "" {
+		o.MaxInterval, err = time.ParseDuration(options.MaxInterval)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing MaxInterval '%s'", options.MaxInterval)
+		}
+	}
+
+	return nil
+}
+
+// WithDefaults returns the options with every unset value taken from defaults
+func (o RetryOptions) WithDefaults(defaults RetryOptions) RetryOptions {
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if o.InitialInterval == 0 {
+		o.InitialInterval = defaults.InitialInterval
+	}
+
+	if o.MaxInterval == 0 {
+		o.MaxInterval = defaults.MaxInterval
+	}
+
+	if o.Sleeper == nil {
+		o.Sleeper = defaults.Sleeper
+	}
+
+	return o
+}
+
+// APIError is returned once a SoftLayer API request has failed for good. It
+// satisfies api.CloudError and api.RetryableError so that, when it reaches
+// the director, it tells whether retrying the whole operation could help.
+type APIError struct {
+	Path      string
+	Attempts  int
+	Reason    string
+	Transient bool
+}
+
+func (e APIError) Type() string { return "Bosh::Clouds::CloudError" }
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("SoftLayer API request '%s' failed after %d attempt(s): %s", e.Path, e.Attempts, e.Reason)
+}
+
+func (e APIError) CanRetry() bool { return e.Transient }
+
+// RetryingClient wraps a SoftLayer client so that every request, including
+// those made by the services it hands out, is retried with exponential
+// backoff while SoftLayer answers with transient errors
+type RetryingClient struct {
+	hookedClient
+
+	options RetryOptions
+	random  *rand.Rand
+	logger  boshlog.Logger
+}
+
+func NewRetryingClient(client sl.Client, options RetryOptions, logger boshlog.Logger) RetryingClient {
+	c := RetryingClient{
+		options: options,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:  logger,
+	}
+	c.hookedClient = newHookedClient(client, c.retry)
+
+	return c
+}
+
+// retry sends the request until it succeeds, fails with an error that is not
+// transient, or MaxAttempts is reached. The body is replayed on each attempt
+// since sending it drains the buffer.
+//
+// Only reads are sent again after SoftLayer may have seen them. Any other
+// request, e.g. createObject or placeOrder, may have been carried out before
+// timing out, so it is retried only when it could not even be sent.
+func (c RetryingClient) retry(path string, requestType string, requestBody *bytes.Buffer, request func(*bytes.Buffer) ([]byte, error)) ([]byte, error) {
+	var payload []byte
+	if requestBody != nil {
+		payload = requestBody.Bytes()
+	}
+
+	interval := c.options.InitialInterval
+	for attempt := 1; ; attempt++ {
+		response, err := request(bytes.NewBuffer(payload))
+
+		reason, transient := classifyResponse(response, err)
+		if !transient {
+			if err != nil {
+				return response, APIError{Path: path, Attempts: attempt, Reason: err.Error(), Transient: false}
+			}
+
+			return response, nil
+		}
+
+		if requestType != "GET" && !wasNeverSent(err) {
+			// Only a fault SoftLayer answered with shows the request was
+			// refused rather than carried out
+			return nil, APIError{Path: path, Attempts: attempt, Reason: reason, Transient: err == nil && isFault(response)}
+		}
+
+		if attempt >= c.options.MaxAttempts {
+			return nil, APIError{Path: path, Attempts: attempt, Reason: reason, Transient: true}
+		}
+
+		c.logger.Debug(retryingClientLogTag, "Retrying SoftLayer API request '%s' after attempt %d of %d failed: %s", path, attempt, c.options.MaxAttempts, reason)
+
+		c.sleep(c.jitter(interval))
+
+		interval *= 2
+		if interval > c.options.MaxInterval {
+			interval = c.options.MaxInterval
+		}
+	}
+}
+
+// jitter picks a duration between half of and the full interval
+func (c RetryingClient) jitter(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	half := interval / 2
+	return half + time.Duration(c.random.Int63n(int64(interval-half)+1))
+}
+
+func (c RetryingClient) sleep(d time.Duration) {
+	if c.options.Sleeper == nil {
+		util.RealSleeper{}.Sleep(d)
+		return
+	}
+
+	c.options.Sleeper.Sleep(d)
+}
+
+var (
+	transientMessages = []string{
+		"try again",
+		"timeout",
+		"timed out",
+		"temporarily unavailable",
+		"service unavailable",
+		"bad gateway",
+		"connection reset",
+		"connection refused",
+		"eof",
+	}
+
+	serverErrorStatus = regexp.MustCompile(`\b5\d\d\b`)
+)
+
+// classifyResponse tells whether a request failed in a way that may succeed
+// when sent again. softlayer-go does not expose HTTP status codes, so
+// transport errors, SoftLayer faults and gateway error pages are recognised
+// by their content.
+func classifyResponse(response []byte, err error) (string, bool) {
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && (netErr.Timeout() || netErr.Temporary()) {
+			return err.Error(), true
+		}
+
+		return err.Error(), hasTransientMessage(err.Error())
+	}
+
+	trimmed := bytes.TrimSpace(response)
+
+	if isFault(trimmed) {
+		fault := struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}{}
+
+		if json.Unmarshal(trimmed, &fault) != nil || fault.Error == "" {
+			return "", false
+		}
+
+		reason := fmt.Sprintf("%s: %s", fault.Code, fault.Error)
+		return reason, hasTransientMessage(reason)
+	}
+
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		page := string(trimmed)
+		if serverErrorStatus.MatchString(page) || hasTransientMessage(page) {
+			return "SoftLayer API is temporarily unavailable, it answered with a server error page", true
+		}
+	}
+
+	return "", false
+}
+
+// wasNeverSent tells whether err shows that no connection to SoftLayer could
+// be made, in which case the request cannot have been carried out
+func wasNeverSent(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+func isFault(response []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(response), []byte("{"))
+}
+
+// IsTransientError tells whether err was caused by a SoftLayer API failure
+// that may go away when the operation is tried again. Only an APIError found
+// in the chain of causes of err counts.
+func IsTransientError(err error) bool {
+	apiErr, found := bslcapi.FindError(err, func(err error) bool {
+		_, ok := err.(APIError)
+		return ok
+	})
+	if !found {
+		return false
+	}
+
+	return apiErr.(APIError).CanRetry()
+}
+
+func hasTransientMessage(message string) bool {
+	message = strings.ToLower(message)
+
+	for _, transientMessage := range transientMessages {
+		if strings.Contains(message, transientMessage) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,208 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+type fakeTimeoutError struct{}
+
+func (e fakeTimeoutError) Error() string   { return "fake-dial-err" }
+func (e fakeTimeoutError) Timeout() bool   { return true }
+func (e fakeTimeoutError) Temporary() bool { return false }
+
+var _ = Describe("RetryingClient", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		sleeper         *util.RecordingNoopSleeper
+		client          RetryingClient
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		sleeper = util.NewRecordingNoopSleeper()
+
+		options := RetryOptions{
+			MaxAttempts:     4,
+			InitialInterval: 1 * time.Second,
+			MaxInterval:     3 * time.Second,
+			Sleeper:         sleeper,
+		}
+		client = NewRetryingClient(softLayerClient, options, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("DoRawHttpRequest", func() {
+		It("returns the response without retrying when the request succeeds", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("true")
+
+			response, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response).To(Equal([]byte("true")))
+			Expect(sleeper.SleptTimes()).To(BeEmpty())
+		})
+
+		It("retries SoftLayer faults asking to try again", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{
+				[]byte(`{"error": "Please try again later.", "code": "SoftLayer_Exception_Public"}`),
+				[]byte("<html><title>503 Service Unavailable</title></html>"),
+				[]byte("true"),
+			}
+
+			response, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response).To(Equal([]byte("true")))
+			Expect(sleeper.SleptTimes()).To(HaveLen(2))
+		})
+
+		It("returns SoftLayer faults that are not transient without retrying", func() {
+			fault := []byte(`{"error": "Unable to find object with id of '1234'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+			softLayerClient.DoRawHttpRequestResponse = fault
+
+			response, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response).To(Equal(fault))
+			Expect(sleeper.SleptTimes()).To(BeEmpty())
+		})
+
+		It("backs off exponentially with jitter up to MaxInterval and returns a retryable APIError", func() {
+			softLayerClient.DoRawHttpRequestError = fakeTimeoutError{}
+
+			_, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).To(Equal(APIError{Path: "fake-path", Attempts: 4, Reason: "fake-dial-err", Transient: true}))
+			Expect(err.(APIError).CanRetry()).To(BeTrue())
+
+			sleptTimes := sleeper.SleptTimes()
+			Expect(sleptTimes).To(HaveLen(3))
+			for i, max := range []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second} {
+				Expect(sleptTimes[i]).To(BeNumerically(">=", max/2))
+				Expect(sleptTimes[i]).To(BeNumerically("<=", max))
+			}
+		})
+
+		It("returns an APIError that cannot be retried when the error is not transient", func() {
+			softLayerClient.DoRawHttpRequestError = errors.New("fake-request-err")
+
+			_, err := client.DoRawHttpRequest("fake-path", "GET", new(bytes.Buffer))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("SoftLayer API request 'fake-path' failed after 1 attempt(s): fake-request-err"))
+			Expect(err.(APIError).CanRetry()).To(BeFalse())
+			Expect(sleeper.SleptTimes()).To(BeEmpty())
+		})
+
+		Context("when the request is not a GET", func() {
+			It("does not send it again after it timed out", func() {
+				softLayerClient.DoRawHttpRequestError = fakeTimeoutError{}
+
+				_, err := client.DoRawHttpRequest("fake-path", "POST", new(bytes.Buffer))
+				Expect(err).To(Equal(APIError{Path: "fake-path", Attempts: 1, Reason: "fake-dial-err", Transient: false}))
+				Expect(err.(APIError).CanRetry()).To(BeFalse())
+				Expect(sleeper.SleptTimes()).To(BeEmpty())
+			})
+
+			It("retries it when no connection could be made", func() {
+				softLayerClient.DoRawHttpRequestError = &url.Error{
+					Op:  "Post",
+					URL: "fake-url",
+					Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+				}
+
+				_, err := client.DoRawHttpRequest("fake-path", "POST", new(bytes.Buffer))
+				Expect(err).To(HaveOccurred())
+				Expect(err.(APIError).Attempts).To(Equal(4))
+				Expect(err.(APIError).CanRetry()).To(BeTrue())
+				Expect(sleeper.SleptTimes()).To(HaveLen(3))
+			})
+
+			It("does not send it again after a fault asking to try again but lets the director retry it", func() {
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{
+					[]byte(`{"error": "Please try again later.", "code": "SoftLayer_Exception_Public"}`),
+					[]byte("true"),
+				}
+
+				_, err := client.DoRawHttpRequest("fake-path", "POST", new(bytes.Buffer))
+				Expect(err).To(HaveOccurred())
+				Expect(err.(APIError).Attempts).To(Equal(1))
+				Expect(err.(APIError).CanRetry()).To(BeTrue())
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(1))
+			})
+
+			It("does not let the director retry it after a server error page", func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte("<html><title>504 Gateway Timeout</title></html>")
+
+				_, err := client.DoRawHttpRequest("fake-path", "POST", new(bytes.Buffer))
+				Expect(err).To(HaveOccurred())
+				Expect(err.(APIError).CanRetry()).To(BeFalse())
+				Expect(sleeper.SleptTimes()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("services", func() {
+		It("sends their requests through the retrying client", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{
+				[]byte(`{"error": "The request timed out.", "code": "SoftLayer_Exception_Public"}`),
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Guest_Service_getPowerState.json"})
+
+			virtualGuestService, err := client.GetSoftLayer_Virtual_Guest_Service()
+			Expect(err).ToNot(HaveOccurred())
+
+			powerState, err := virtualGuestService.GetPowerState(1234567)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(powerState.KeyName).To(Equal("RUNNING"))
+			Expect(sleeper.SleptTimes()).To(HaveLen(1))
+		})
+	})
+})
+
+var _ = Describe("IsTransientError", func() {
+	It("tells whether an APIError can be retried", func() {
+		Expect(IsTransientError(APIError{Path: "fake-path", Attempts: 1, Reason: "fake-reason", Transient: true})).To(BeTrue())
+		Expect(IsTransientError(APIError{Path: "fake-path", Attempts: 1, Reason: "fake-reason", Transient: false})).To(BeFalse())
+	})
+
+	It("finds a wrapped APIError", func() {
+		err := bslcapi.WrapError(APIError{Path: "fake-path", Attempts: 1, Reason: "fake-reason", Transient: true}, "fake-wrap")
+		Expect(IsTransientError(err)).To(BeTrue())
+	})
+
+	It("does not treat other errors as transient whatever their message", func() {
+		Expect(IsTransientError(errors.New("fake-err: connection reset by peer"))).To(BeFalse())
+	})
+})
+
+var _ = Describe("RetryOptions", func() {
+	It("reads intervals as duration strings", func() {
+		var options RetryOptions
+
+		err := json.Unmarshal([]byte(`{"MaxAttempts": 3, "InitialInterval": "1s", "MaxInterval": "1m"}`), &options)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal(RetryOptions{MaxAttempts: 3, InitialInterval: 1 * time.Second, MaxInterval: 1 * time.Minute}))
+	})
+
+	It("fills unset values from the defaults", func() {
+		options := RetryOptions{MaxAttempts: 2}.WithDefaults(DefaultRetryOptions)
+		Expect(options).To(Equal(RetryOptions{
+			MaxAttempts:     2,
+			InitialInterval: DefaultRetryOptions.InitialInterval,
+			MaxInterval:     DefaultRetryOptions.MaxInterval,
+		}))
+	})
+})
@@ -1,161 +1,283 @@
 package common
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"time"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
-)
 
-var (
-	TIMEOUT          time.Duration
-	POLLING_INTERVAL time.Duration
-	MAX_RETRY_COUNT  int
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
 )
 
-func AttachEphemeralDiskToVirtualGuest(softLayerClient sl.Client, virtualGuestId int, diskSize int, timeout, pollingInterval time.Duration) error {
-	err := WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", timeout, pollingInterval)
+func ConfigureMetadataOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int, metadata string, waitOptions WaitOptions) error {
+	err := WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", waitOptions)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
 	}
 
-	err = WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, virtualGuestId, timeout, pollingInterval)
+	err = WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, virtualGuestId, waitOptions)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", virtualGuestId))
 	}
 
-	service, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+	err = SetMetadataOnVirtualGuest(softLayerClient, virtualGuestId, metadata)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Attaching ephemeral disk to VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Setting metadata on VirtualGuest `%d`", virtualGuestId))
 	}
 
-	err = service.AttachEphemeralDisk(virtualGuestId, diskSize)
+	err = ConfigureMetadataDiskOnVirtualGuest(softLayerClient, virtualGuestId)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Attaching ephemeral disk to VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Configuring metadata disk on VirtualGuest `%d`", virtualGuestId))
 	}
 
-	return nil
-}
-
-func ConfigureMetadataOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int, metadata string, timeout, pollingInterval time.Duration) error {
-	err := WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", timeout, pollingInterval)
+	err = WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", waitOptions)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
 	}
 
-	err = WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, virtualGuestId, timeout, pollingInterval)
-	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", virtualGuestId))
-	}
+	return nil
+}
 
-	err = SetMetadataOnVirtualGuest(softLayerClient, virtualGuestId, metadata)
+func WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient sl.Client, virtualGuestId int, waitOptions WaitOptions) error {
+	virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Setting metadata on VirtualGuest `%d`", virtualGuestId))
+		return bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
 	}
 
-	err = ConfigureMetadataDiskOnVirtualGuest(softLayerClient, virtualGuestId)
+	done, err := waitOptions.Poll(func() (bool, error) {
+		activeTransactions, err := virtualGuestService.GetActiveTransactions(virtualGuestId)
+		if err != nil {
+			return false, err
+		}
+
+		return len(activeTransactions) == 0, nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Configuring metadata disk on VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, "Getting active transactions from SoftLayer client")
 	}
 
-	err = WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", timeout, pollingInterval)
-	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
+	if !done {
+		return bosherr.Errorf("Waiting for virtual guest with ID '%d' to have no active transactions", virtualGuestId)
 	}
 
 	return nil
 }
 
-func WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient sl.Client, virtualGuestId int, timeout, pollingInterval time.Duration) error {
+// WaitForVirtualGuestToReboot waits for a reboot that was just requested to
+// start, since the guest keeps reporting RUNNING while it is queued, and then
+// for the guest to be RUNNING again with no active transactions
+func WaitForVirtualGuestToReboot(softLayerClient sl.Client, virtualGuestId int, waitOptions WaitOptions) error {
 	virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
 	if err != nil {
 		return bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
 	}
 
-	retryCount := 0
-	totalTime := time.Duration(0)
-	for totalTime < timeout {
+	started, err := waitOptions.Poll(func() (bool, error) {
 		activeTransactions, err := virtualGuestService.GetActiveTransactions(virtualGuestId)
 		if err != nil {
-			if retryCount > MAX_RETRY_COUNT {
-				return bosherr.WrapError(err, "Getting active transactions from SoftLayer client")
-			} else {
-				retryCount += 1
-				continue
-			}
+			return false, err
+		}
+
+		if len(activeTransactions) > 0 {
+			return true, nil
 		}
 
-		if len(activeTransactions) == 0 {
-			return nil
+		vgPowerState, err := virtualGuestService.GetPowerState(virtualGuestId)
+		if err != nil {
+			return false, err
 		}
 
-		totalTime += pollingInterval
-		time.Sleep(pollingInterval)
+		return vgPowerState.KeyName != "RUNNING", nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Getting active transactions and power state from SoftLayer client")
 	}
 
-	return bosherr.Errorf("Waiting for virtual guest with ID '%d' to have no active transactions", virtualGuestId)
+	if !started {
+		return bosherr.Errorf("Waiting for virtual guest with ID '%d' to start rebooting", virtualGuestId)
+	}
+
+	err = WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, virtualGuestId, waitOptions)
+	if err != nil {
+		return err
+	}
+
+	return WaitForVirtualGuest(softLayerClient, virtualGuestId, "RUNNING", waitOptions)
 }
 
-func WaitForVirtualGuest(softLayerClient sl.Client, virtualGuestId int, targetState string, timeout, pollingInterval time.Duration) error {
+func WaitForVirtualGuest(softLayerClient sl.Client, virtualGuestId int, targetState string, waitOptions WaitOptions) error {
 	virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
 	if err != nil {
 		return bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
 	}
 
-	retryCount := 0
-	totalTime := time.Duration(0)
-	for totalTime < timeout {
+	done, err := waitOptions.Poll(func() (bool, error) {
 		vgPowerState, err := virtualGuestService.GetPowerState(virtualGuestId)
 		if err != nil {
-			if retryCount > MAX_RETRY_COUNT {
-				return bosherr.WrapError(err, "Getting active transactions from SoftLayer client")
-			} else {
-				retryCount += 1
-				continue
-			}
+			return false, err
 		}
 
-		if vgPowerState.KeyName == targetState {
-			return nil
-		}
+		return vgPowerState.KeyName == targetState, nil
+	})
+	if err != nil {
+		return bslcapi.WrapError(err, "Getting power state from SoftLayer client")
+	}
 
-		totalTime += pollingInterval
-		time.Sleep(pollingInterval)
+	if !done {
+		return bosherr.Errorf("Waiting for virtual guest with ID '%d' to have be in state '%s'", virtualGuestId, targetState)
 	}
 
-	return bosherr.Errorf("Waiting for virtual guest with ID '%d' to have be in state '%s'", virtualGuestId, targetState)
+	return nil
 }
 
 func SetMetadataOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int, metadata string) error {
+	err := SetUserMetadata(softLayerClient, fmt.Sprintf("SoftLayer_Virtual_Guest/%d", virtualGuestId), metadata)
+	if err != nil {
+		return bslcapi.WrapError(err, fmt.Sprintf("Setting metadata on VirtualGuest `%d`", virtualGuestId))
+	}
+
+	return nil
+}
+
+func ConfigureMetadataDiskOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int) error {
 	virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
 	if err != nil {
 		return bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
 	}
 
-	success, err := virtualGuestService.SetMetadata(virtualGuestId, metadata)
+	_, err = virtualGuestService.ConfigureMetadataDisk(virtualGuestId)
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Setting metadata on VirtualGuest `%d`", virtualGuestId))
+		return bslcapi.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", virtualGuestId))
 	}
 
-	if !success {
-		return bosherr.WrapError(err, fmt.Sprintf("Failed to set metadata on VirtualGuest `%d`", virtualGuestId))
+	return nil
+}
+
+// SetUserMetadata hands metadata to the virtual guest or bare-metal server
+// at objectPath, e.g. "SoftLayer_Hardware_Server/1234"
+func SetUserMetadata(softLayerClient sl.Client, objectPath string, metadata string) error {
+	encodedMetadata := base64.StdEncoding.EncodeToString([]byte(metadata))
+
+	return setOnObject(softLayerClient, objectPath, "setUserMetadata", [][]string{[]string{encodedMetadata}})
+}
+
+// SetTags replaces the tags of the virtual guest or bare-metal server at
+// objectPath, e.g. "SoftLayer_Virtual_Guest/1234"
+func SetTags(softLayerClient sl.Client, objectPath string, tags []string) error {
+	return setOnObject(softLayerClient, objectPath, "setTags", []string{strings.Join(tags, ",")})
+}
+
+func setOnObject(softLayerClient sl.Client, objectPath string, method string, parameters interface{}) error {
+	requestBody, err := json.Marshal(map[string]interface{}{"parameters": parameters})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling %s parameters", method)
+	}
+
+	response, err := softLayerClient.DoRawHttpRequest(fmt.Sprintf("%s/%s.json", objectPath, method), "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+
+	if res := string(response); res != "true" {
+		return bosherr.Errorf("Failed to %s on %s, got '%s' as response from the API", method, objectPath, res)
 	}
 
 	return nil
 }
 
-func ConfigureMetadataDiskOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int) error {
-	virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+func WaitForVirtualGuestBlockDeviceTemplateGroup(softLayerClient sl.Client, templateGroupId int, targetState string, waitOptions WaitOptions) error {
+	templateGroupService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
 	if err != nil {
-		return bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
+		return bosherr.WrapError(err, "Creating VirtualGuestBlockDeviceTemplateGroupService from SoftLayer client")
 	}
 
-	_, err = virtualGuestService.ConfigureMetadataDisk(virtualGuestId)
+	done, err := waitOptions.Poll(func() (bool, error) {
+		status, err := templateGroupService.GetStatus(templateGroupId)
+		if err != nil {
+			return false, err
+		}
+
+		return status.KeyName == targetState, nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Getting status from SoftLayer client")
+	}
+
+	if !done {
+		return bosherr.Errorf("Waiting for virtual guest block device template group with ID '%d' to be in state '%s'", templateGroupId, targetState)
+	}
+
+	return nil
+}
+
+// WaitForVirtualGuestBlockDeviceTemplateGroupInDatacenter waits for a
+// template group to list the datacenter it was just replicated to; its status
+// stays ACTIVE all along and so does not tell when the copy is there
+func WaitForVirtualGuestBlockDeviceTemplateGroupInDatacenter(softLayerClient sl.Client, templateGroupId int, datacenterName string, waitOptions WaitOptions) error {
+	templateGroupService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
+	if err != nil {
+		return bosherr.WrapError(err, "Creating VirtualGuestBlockDeviceTemplateGroupService from SoftLayer client")
+	}
+
+	done, err := waitOptions.Poll(func() (bool, error) {
+		locations, err := templateGroupService.GetDatacenters(templateGroupId)
+		if err != nil {
+			return false, err
+		}
+
+		for _, location := range locations {
+			if location.Name == datacenterName {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", virtualGuestId))
+		return bosherr.WrapError(err, "Getting datacenters from SoftLayer client")
+	}
+
+	if !done {
+		return bosherr.Errorf("Waiting for virtual guest block device template group with ID '%d' to be in datacenter '%s'", templateGroupId, datacenterName)
 	}
 
 	return nil
 }
+
+func GetIscsiVolumesAllowedOnVirtualGuest(softLayerClient sl.Client, virtualGuestId int) ([]sldatatypes.SoftLayer_Network_Storage, error) {
+	response, err := softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%d/getAllowedNetworkStorage.json", virtualGuestId), "GET", new(bytes.Buffer))
+	if err != nil {
+		return []sldatatypes.SoftLayer_Network_Storage{}, bosherr.WrapError(err, fmt.Sprintf("Getting network storage allowed on VirtualGuest `%d`", virtualGuestId))
+	}
+
+	networkStorages := []sldatatypes.SoftLayer_Network_Storage{}
+	err = json.Unmarshal(response, &networkStorages)
+	if err != nil {
+		return []sldatatypes.SoftLayer_Network_Storage{}, bosherr.WrapError(err, "Unmarshalling network storage")
+	}
+
+	iscsiVolumes := []sldatatypes.SoftLayer_Network_Storage{}
+	for _, networkStorage := range networkStorages {
+		if networkStorage.NasType == "ISCSI" {
+			iscsiVolumes = append(iscsiVolumes, networkStorage)
+		}
+	}
+
+	return iscsiVolumes, nil
+}
+
+// IsNotFoundFault tells whether a SoftLayer response is the fault returned
+// for an object that does not exist
+func IsNotFoundFault(response []byte) bool {
+	fault := struct {
+		Code string `json:"code"`
+	}{}
+
+	return json.Unmarshal(response, &fault) == nil && fault.Code == "SoftLayer_Exception_ObjectNotFound"
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"encoding/json"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+)
+
+// WaitOptions controls how long and how often SoftLayer is polled while
+// waiting for a resource to reach a state
+type WaitOptions struct {
+	Timeout         time.Duration
+	PollingInterval time.Duration
+
+	// Number of failed API calls tolerated while polling
+	MaxRetryCount int
+
+	// Defaults to a real sleeper when not set
+	Sleeper util.Sleeper
+}
+
+// OperationWaitOptions holds separate WaitOptions for each kind of operation
+// since, for example, provisioning a VM takes far longer than attaching a disk
+type OperationWaitOptions struct {
+	Create   WaitOptions
+	Metadata WaitOptions
+	Attach   WaitOptions
+	Delete   WaitOptions
+
+	// Bare-metal servers can take hours to be provisioned
+	BareMetal WaitOptions
+}
+
+var (
+	DefaultCreateWaitOptions = WaitOptions{Timeout: 20 * time.Minute, PollingInterval: 20 * time.Second, MaxRetryCount: 5}
+	DefaultWaitOptions       = WaitOptions{Timeout: 10 * time.Minute, PollingInterval: 10 * time.Second, MaxRetryCount: 5}
+
+	DefaultBareMetalWaitOptions = WaitOptions{Timeout: 2 * time.Hour, PollingInterval: 1 * time.Minute, MaxRetryCount: 5}
+)
+
+type waitOptionsJSON struct {
+	Timeout         string
+	PollingInterval string
+	MaxRetryCount   int
+}
+
+// UnmarshalJSON reads Timeout and PollingInterval as duration strings
+// such as "10m" or "30s"
+func (o *WaitOptions) UnmarshalJSON(data []byte) error {
+	var options waitOptionsJSON
+
+	err := json.Unmarshal(data, &options)
+	if err != nil {
+		return err
+	}
+
+	*o = WaitOptions{MaxRetryCount: options.MaxRetryCount}
+
+	if options.Timeout != "" {
+		o.Timeout, err = time.ParseDuration(options.Timeout)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing Timeout '%s'", options.Timeout)
+		}
+	}
+
+	if options.PollingInterval != "" {
+		o.PollingInterval, err = time.ParseDuration(options.PollingInterval)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing PollingInterval '%s'", options.PollingInterval)
+		}
+	}
+
+	return nil
+}
+
+// WithDefaults returns the options with every unset value taken from defaults
+func (o WaitOptions) WithDefaults(defaults WaitOptions) WaitOptions {
+	if o.Timeout == 0 {
+		o.Timeout = defaults.Timeout
+	}
+
+	if o.PollingInterval == 0 {
+		o.PollingInterval = defaults.PollingInterval
+	}
+
+	if o.MaxRetryCount == 0 {
+		o.MaxRetryCount = defaults.MaxRetryCount
+	}
+
+	if o.Sleeper == nil {
+		o.Sleeper = defaults.Sleeper
+	}
+
+	return o
+}
+
+// WithDefaults returns the options of each operation with every unset value
+// taken from DefaultCreateWaitOptions, DefaultBareMetalWaitOptions or
+// DefaultWaitOptions
+func (o OperationWaitOptions) WithDefaults() OperationWaitOptions {
+	return OperationWaitOptions{
+		Create:   o.Create.WithDefaults(DefaultCreateWaitOptions),
+		Metadata: o.Metadata.WithDefaults(DefaultWaitOptions),
+		Attach:   o.Attach.WithDefaults(DefaultWaitOptions),
+		Delete:   o.Delete.WithDefaults(DefaultWaitOptions),
+
+		BareMetal: o.BareMetal.WithDefaults(DefaultBareMetalWaitOptions),
+	}
+}
+
+func (o WaitOptions) sleep() {
+	if o.Sleeper == nil {
+		util.RealSleeper{}.Sleep(o.PollingInterval)
+		return
+	}
+
+	o.Sleeper.Sleep(o.PollingInterval)
+}
+
+// Poll calls done every PollingInterval until it reports true or Timeout
+// elapses, tolerating up to MaxRetryCount failed calls. Failed calls wait out
+// the interval like any other, and an APIError that cannot be retried ends
+// polling right away.
+func (o WaitOptions) Poll(done func() (bool, error)) (bool, error) {
+	retryCount := 0
+	totalTime := time.Duration(0)
+	for totalTime < o.Timeout {
+		isDone, err := done()
+		if err != nil {
+			if apiErr, ok := err.(APIError); ok && !apiErr.CanRetry() {
+				return false, err
+			}
+
+			if retryCount >= o.MaxRetryCount {
+				return false, err
+			}
+
+			retryCount += 1
+		} else if isDone {
+			return true, nil
+		}
+
+		totalTime += o.PollingInterval
+		o.sleep()
+	}
+
+	return false, nil
+}
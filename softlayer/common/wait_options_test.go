@@ -0,0 +1,124 @@
+package common_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("WaitOptions", func() {
+	Describe("UnmarshalJSON", func() {
+		It("reads durations as strings", func() {
+			var options OperationWaitOptions
+
+			err := json.Unmarshal([]byte(`{"Create": {"Timeout": "30m", "PollingInterval": "30s", "MaxRetryCount": 3}}`), &options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options.Create).To(Equal(WaitOptions{Timeout: 30 * time.Minute, PollingInterval: 30 * time.Second, MaxRetryCount: 3}))
+			Expect(options.Attach).To(Equal(WaitOptions{}))
+		})
+
+		It("returns error if a duration cannot be parsed", func() {
+			var options WaitOptions
+
+			err := json.Unmarshal([]byte(`{"Timeout": "fake-timeout"}`), &options)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Parsing Timeout 'fake-timeout'"))
+		})
+	})
+
+	Describe("WithDefaults", func() {
+		It("fills unset values of each operation from the defaults", func() {
+			options := OperationWaitOptions{
+				Attach: WaitOptions{Timeout: 1 * time.Minute},
+			}.WithDefaults()
+
+			Expect(options.Create).To(Equal(DefaultCreateWaitOptions))
+			Expect(options.Metadata).To(Equal(DefaultWaitOptions))
+			Expect(options.Delete).To(Equal(DefaultWaitOptions))
+			Expect(options.BareMetal).To(Equal(DefaultBareMetalWaitOptions))
+			Expect(options.Attach).To(Equal(WaitOptions{
+				Timeout:         1 * time.Minute,
+				PollingInterval: DefaultWaitOptions.PollingInterval,
+				MaxRetryCount:   DefaultWaitOptions.MaxRetryCount,
+			}))
+		})
+	})
+})
+
+var _ = Describe("WaitForVirtualGuest", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		sleeper         *util.RecordingNoopSleeper
+		waitOptions     WaitOptions
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		sleeper = util.NewRecordingNoopSleeper()
+		waitOptions = WaitOptions{Timeout: 3 * time.Second, PollingInterval: 1 * time.Second, Sleeper: sleeper}
+	})
+
+	It("polls until the virtual guest reaches the target state", func() {
+		fileNames := []string{
+			"SoftLayer_Virtual_Guest_Service_getPowerState_Halted.json",
+			"SoftLayer_Virtual_Guest_Service_getPowerState_Halted.json",
+			"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+		}
+		common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+		err := WaitForVirtualGuest(softLayerClient, 1234567, "RUNNING", waitOptions)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sleeper.SleptTimes()).To(Equal([]time.Duration{1 * time.Second, 1 * time.Second}))
+	})
+
+	It("returns error once the timeout elapses", func() {
+		common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getPowerState_Halted.json")
+
+		err := WaitForVirtualGuest(softLayerClient, 1234567, "RUNNING", waitOptions)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("to have be in state 'RUNNING'"))
+		Expect(sleeper.SleptTimes()).To(HaveLen(3))
+	})
+})
+
+var _ = Describe("WaitForVirtualGuestToHaveNoRunningTransactions", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		sleeper         *util.RecordingNoopSleeper
+		waitOptions     WaitOptions
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		sleeper = util.NewRecordingNoopSleeper()
+		waitOptions = WaitOptions{Timeout: 10 * time.Second, PollingInterval: 1 * time.Second, MaxRetryCount: 2, Sleeper: sleeper}
+	})
+
+	It("waits out the polling interval after failed calls", func() {
+		softLayerClient.DoRawHttpRequestError = errors.New("fake-request-err")
+
+		err := WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, 1234567, waitOptions)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-request-err"))
+		Expect(sleeper.SleptTimes()).To(Equal([]time.Duration{1 * time.Second, 1 * time.Second}))
+	})
+
+	It("stops polling on an APIError that cannot be retried", func() {
+		softLayerClient.DoRawHttpRequestError = APIError{Path: "fake-path", Attempts: 1, Reason: "fake-reason"}
+
+		err := WaitForVirtualGuestToHaveNoRunningTransactions(softLayerClient, 1234567, waitOptions)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-reason"))
+		Expect(sleeper.SleptTimes()).To(BeEmpty())
+	})
+})
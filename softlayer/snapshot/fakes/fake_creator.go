@@ -0,0 +1,18 @@
+package fakes
+
+import (
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+type FakeCreator struct {
+	CreateDiskID   int
+	CreateMetadata bslcsnap.SnapshotMetadata
+	CreateSnapshot bslcsnap.Snapshot
+	CreateErr      error
+}
+
+func (c *FakeCreator) Create(diskId int, metadata bslcsnap.SnapshotMetadata) (bslcsnap.Snapshot, error) {
+	c.CreateDiskID = diskId
+	c.CreateMetadata = metadata
+	return c.CreateSnapshot, c.CreateErr
+}
@@ -0,0 +1,17 @@
+package fakes
+
+import (
+	bslcsnap "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+type FakeFinder struct {
+	FindID       int
+	FindSnapshot bslcsnap.Snapshot
+	FindFound    bool
+	FindErr      error
+}
+
+func (f *FakeFinder) Find(id int) (bslcsnap.Snapshot, bool, error) {
+	f.FindID = id
+	return f.FindSnapshot, f.FindFound, f.FindErr
+}
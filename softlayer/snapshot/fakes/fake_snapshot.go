@@ -0,0 +1,19 @@
+package fakes
+
+type FakeSnapshot struct {
+	id int
+
+	DeleteCalled bool
+	DeleteErr    error
+}
+
+func NewFakeSnapshot(id int) *FakeSnapshot {
+	return &FakeSnapshot{id: id}
+}
+
+func (s FakeSnapshot) ID() int { return s.id }
+
+func (s *FakeSnapshot) Delete() error {
+	s.DeleteCalled = true
+	return s.DeleteErr
+}
@@ -0,0 +1,17 @@
+package snapshot
+
+type SnapshotMetadata map[string]interface{}
+
+type Creator interface {
+	Create(diskId int, metadata SnapshotMetadata) (Snapshot, error)
+}
+
+type Finder interface {
+	Find(id int) (Snapshot, bool, error)
+}
+
+type Snapshot interface {
+	ID() int
+
+	Delete() error
+}
@@ -0,0 +1,61 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+	sl "github.com/maximilien/softlayer-go/softlayer"
+)
+
+const softLayerCreatorLogTag = "SoftLayerCreator"
+
+type SoftLayerCreator struct {
+	softLayerClient sl.Client
+	logger          boshlog.Logger
+}
+
+func NewSoftLayerSnapshotCreator(client sl.Client, logger boshlog.Logger) SoftLayerCreator {
+	return SoftLayerCreator{
+		softLayerClient: client,
+		logger:          logger,
+	}
+}
+
+func (c SoftLayerCreator) Create(diskId int, metadata SnapshotMetadata) (Snapshot, error) {
+	c.logger.Debug(softLayerCreatorLogTag, "Creating snapshot of disk '%d'", diskId)
+
+	notes, err := json.Marshal(metadata)
+	if err != nil {
+		return SoftLayerSnapshot{}, bosherr.WrapError(err, "Marshalling snapshot metadata")
+	}
+
+	parameters := map[string]interface{}{
+		"parameters": []string{string(notes)},
+	}
+
+	requestBody, err := json.Marshal(parameters)
+	if err != nil {
+		return SoftLayerSnapshot{}, bosherr.WrapError(err, "Marshalling snapshot parameters")
+	}
+
+	response, err := c.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Network_Storage/%d/createSnapshot.json", diskId), "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return SoftLayerSnapshot{}, bosherr.WrapErrorf(err, "Create snapshot of iSCSI disk %d error.", diskId)
+	}
+
+	snapshot := sldatatypes.SoftLayer_Network_Storage{}
+	err = json.Unmarshal(response, &snapshot)
+	if err != nil {
+		return SoftLayerSnapshot{}, bosherr.WrapError(err, "Unmarshalling snapshot")
+	}
+
+	if snapshot.Id == 0 {
+		return SoftLayerSnapshot{}, bosherr.Errorf("Create snapshot of iSCSI disk %d error, got '%s' as response from the API", diskId, string(response))
+	}
+
+	return NewSoftLayerSnapshot(snapshot.Id, c.softLayerClient, c.logger), nil
+}
@@ -0,0 +1,57 @@
+package snapshot_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	fakeclient "github.com/maximilien/softlayer-go/client/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+var _ = Describe("SoftLayerCreator", func() {
+	var (
+		fc       *fakeclient.FakeSoftLayerClient
+		logger   boshlog.Logger
+		creator  SoftLayerCreator
+		metadata SnapshotMetadata
+	)
+
+	BeforeEach(func() {
+		fc = fakeclient.NewFakeSoftLayerClient("fake-user", "fake-key")
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		creator = NewSoftLayerSnapshotCreator(fc, logger)
+		metadata = SnapshotMetadata{"deployment": "fake-deployment"}
+	})
+
+	Describe("Create", func() {
+		It("creates a snapshot of the iSCSI disk successfully", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_createSnapshot.json")
+
+			snapshot, err := creator.Create(1234, metadata)
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedSnapshot := NewSoftLayerSnapshot(5678, fc, logger)
+			Expect(snapshot).To(Equal(expectedSnapshot))
+		})
+
+		It("returns error when the API does not return a snapshot", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_createEmptySnapshot.json")
+
+			_, err := creator.Create(1234, metadata)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns error when the API call fails", func() {
+			fc.DoRawHttpRequestError = errors.New("fake-create-err")
+
+			_, err := creator.Create(1234, metadata)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+		})
+	})
+})
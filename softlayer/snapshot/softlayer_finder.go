@@ -0,0 +1,44 @@
+package snapshot
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	slc "github.com/maximilien/softlayer-go/softlayer"
+)
+
+const softLayerFinderLogTag = "SoftLayerFinder"
+
+const snapshotNasType = "ISCSI_SNAPSHOT"
+
+type SoftLayerFinder struct {
+	softLayerClient slc.Client
+	logger          boshlog.Logger
+}
+
+func NewSoftLayerSnapshotFinder(client slc.Client, logger boshlog.Logger) SoftLayerFinder {
+	return SoftLayerFinder{softLayerClient: client, logger: logger}
+}
+
+func (f SoftLayerFinder) Find(id int) (Snapshot, bool, error) {
+	f.logger.Debug(softLayerFinderLogTag, "Finding snapshot '%d'", id)
+
+	service, err := f.softLayerClient.GetSoftLayer_Network_Storage_Service()
+	if err != nil {
+		return nil, false, bosherr.WrapError(err, "Creating SoftLayer NetworkStorageService from client")
+	}
+
+	// Snapshots are network storage objects too, so anything other than a
+	// snapshot (e.g. a persistent disk) is reported as not found
+	snapshot, err := service.GetIscsiVolume(id)
+	if err != nil {
+		return nil, false, bosherr.WrapErrorf(err, "Getting network storage `%d`", id)
+	}
+
+	if snapshot.Id == 0 || snapshot.NasType != snapshotNasType {
+		return nil, false, nil
+	}
+
+	result := NewSoftLayerSnapshot(id, f.softLayerClient, f.logger)
+
+	return result, true, nil
+}
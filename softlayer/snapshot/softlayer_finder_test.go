@@ -0,0 +1,68 @@
+package snapshot_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	fakeclient "github.com/maximilien/softlayer-go/client/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+)
+
+var _ = Describe("SoftLayerFinder", func() {
+	var (
+		fc     *fakeclient.FakeSoftLayerClient
+		logger boshlog.Logger
+		finder SoftLayerFinder
+	)
+
+	BeforeEach(func() {
+		fc = fakeclient.NewFakeSoftLayerClient("fake-user", "fake-key")
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		finder = NewSoftLayerSnapshotFinder(fc, logger)
+	})
+
+	Describe("Find", func() {
+		It("returns snapshot and found as true when found the snapshot successfully", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_createSnapshot.json")
+
+			snapshot, found, err := finder.Find(5678)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			expectedSnapshot := NewSoftLayerSnapshot(5678, fc, logger)
+			Expect(snapshot).To(Equal(expectedSnapshot))
+		})
+
+		It("returns found as false when failed to find the snapshot", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_getEmptyIscsiVolume.json")
+			snapshot, found, err := finder.Find(5678)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(snapshot).To(BeNil())
+		})
+
+		It("returns found as false when the ID belongs to a disk", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_getIscsiVolume.json")
+			snapshot, found, err := finder.Find(1234)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(snapshot).To(BeNil())
+		})
+
+		It("returns error when getting the network storage fails", func() {
+			fc.DoRawHttpRequestError = errors.New("fake-get-err")
+			_, _, err := finder.Find(5678)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Getting network storage `5678`"))
+			Expect(err.Error()).To(ContainSubstring("fake-get-err"))
+		})
+	})
+})
@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	slc "github.com/maximilien/softlayer-go/softlayer"
+)
+
+const softLayerSnapshotLogTag = "SoftLayerSnapshot"
+
+type SoftLayerSnapshot struct {
+	id              int
+	softLayerClient slc.Client
+	logger          boshlog.Logger
+}
+
+func NewSoftLayerSnapshot(id int, client slc.Client, logger boshlog.Logger) SoftLayerSnapshot {
+	return SoftLayerSnapshot{
+		id:              id,
+		softLayerClient: client,
+		logger:          logger,
+	}
+}
+
+func (s SoftLayerSnapshot) ID() int { return s.id }
+
+func (s SoftLayerSnapshot) Delete() error {
+	s.logger.Debug(softLayerSnapshotLogTag, "Deleting snapshot '%d'", s.id)
+
+	response, err := s.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Network_Storage/%d/deleteObject.json", s.id), "GET", new(bytes.Buffer))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Failed to delete snapshot with id: %d", s.id)
+	}
+
+	if res := string(response); res != "true" {
+		return bosherr.Errorf("Failed to delete snapshot with id: %d, got '%s' as response from the API", s.id, res)
+	}
+
+	return nil
+}
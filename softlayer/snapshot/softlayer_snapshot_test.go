@@ -0,0 +1,50 @@
+package snapshot_test
+
+import (
+	"errors"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	fakeclient "github.com/maximilien/softlayer-go/client/fakes"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/snapshot"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SoftLayerSnapshot", func() {
+	var (
+		fc       *fakeclient.FakeSoftLayerClient
+		snapshot SoftLayerSnapshot
+	)
+
+	BeforeEach(func() {
+		fc = fakeclient.NewFakeSoftLayerClient("fake-user", "fake-key")
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		snapshot = NewSoftLayerSnapshot(5678, fc, logger)
+	})
+
+	Describe("Delete", func() {
+		It("deletes the snapshot successfully", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_deleteObject.json")
+
+			err := snapshot.Delete()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when the API does not delete the snapshot", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(fc, "SoftLayer_Network_Storage_Service_deleteObject_false.json")
+
+			err := snapshot.Delete()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns error when the API call fails", func() {
+			fc.DoRawHttpRequestError = errors.New("fake-delete-err")
+
+			err := snapshot.Delete()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-delete-err"))
+		})
+	})
+})
@@ -0,0 +1,18 @@
+package fakes
+
+import (
+	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+)
+
+type FakeCreator struct {
+	CreateImagePath string
+	CreateMetadata  bslcstem.ImageMetadata
+	CreateStemcell  bslcstem.Stemcell
+	CreateErr       error
+}
+
+func (c *FakeCreator) Create(imagePath string, metadata bslcstem.ImageMetadata) (bslcstem.Stemcell, error) {
+	c.CreateImagePath = imagePath
+	c.CreateMetadata = metadata
+	return c.CreateStemcell, c.CreateErr
+}
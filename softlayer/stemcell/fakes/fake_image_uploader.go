@@ -0,0 +1,28 @@
+package fakes
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+type FakeImageUploader struct {
+	UploadName     string
+	UploadContents []byte
+	UploadSize     int64
+	UploadURI      string
+	UploadErr      error
+}
+
+func (u *FakeImageUploader) Upload(name string, image io.Reader, size int64) (string, error) {
+	u.UploadName = name
+	u.UploadSize = size
+
+	contents, err := ioutil.ReadAll(image)
+	if err != nil {
+		return "", err
+	}
+
+	u.UploadContents = contents
+
+	return u.UploadURI, u.UploadErr
+}
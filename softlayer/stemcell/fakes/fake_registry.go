@@ -0,0 +1,40 @@
+package fakes
+
+import (
+	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+)
+
+type FakeRegistry struct {
+	Records map[int]bslcstem.RegistryRecord
+
+	SaveErr   error
+	FindErr   error
+	DeleteErr error
+}
+
+func NewFakeRegistry() *FakeRegistry {
+	return &FakeRegistry{Records: map[int]bslcstem.RegistryRecord{}}
+}
+
+func (r *FakeRegistry) Save(id int, record bslcstem.RegistryRecord) error {
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+
+	r.Records[id] = record
+	return nil
+}
+
+func (r *FakeRegistry) Find(id int) (bslcstem.RegistryRecord, bool, error) {
+	record, found := r.Records[id]
+	return record, found, r.FindErr
+}
+
+func (r *FakeRegistry) Delete(id int) error {
+	if r.DeleteErr != nil {
+		return r.DeleteErr
+	}
+
+	delete(r.Records, id)
+	return nil
+}
@@ -7,6 +7,9 @@ type FakeStemcell struct {
 	uuid string
 	kind string
 
+	DatacentersResult []string
+	DatacentersErr    error
+
 	DeleteCalled bool
 	DeleteErr    error
 }
@@ -25,6 +28,8 @@ func (s FakeStemcell) Uuid() string { return s.uuid }
 
 func (s FakeStemcell) Kind() string { return s.kind }
 
+func (s FakeStemcell) Datacenters() ([]string, error) { return s.DatacentersResult, s.DatacentersErr }
+
 func (s *FakeStemcell) Delete() error {
 	s.DeleteCalled = true
 	return s.DeleteErr
@@ -0,0 +1,152 @@
+package stemcell
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+const fileRegistryLogTag = "FileRegistry"
+
+// FileRegistry keeps the records of all stemcells in a single JSON file,
+// keyed by stemcell CID. Every access holds a flock on a separate lock file
+// so that concurrent CPI processes do not lose each other's updates.
+type FileRegistry struct {
+	path     string
+	lockPath string
+
+	logger boshlog.Logger
+}
+
+func NewFileRegistry(dir string, logger boshlog.Logger) FileRegistry {
+	return FileRegistry{
+		path:     filepath.Join(dir, "registry.json"),
+		lockPath: filepath.Join(dir, "registry.lock"),
+
+		logger: logger,
+	}
+}
+
+func (r FileRegistry) Save(id int, record RegistryRecord) error {
+	return r.withLock(syscall.LOCK_EX, func() error {
+		records, err := r.read()
+		if err != nil {
+			return err
+		}
+
+		record.RecordedAt = time.Now()
+		records[strconv.Itoa(id)] = record
+
+		return r.write(records)
+	})
+}
+
+func (r FileRegistry) Find(id int) (RegistryRecord, bool, error) {
+	var (
+		record RegistryRecord
+		found  bool
+	)
+
+	err := r.withLock(syscall.LOCK_SH, func() error {
+		records, err := r.read()
+		if err != nil {
+			return err
+		}
+
+		record, found = records[strconv.Itoa(id)]
+
+		return nil
+	})
+
+	return record, found, err
+}
+
+func (r FileRegistry) Delete(id int) error {
+	return r.withLock(syscall.LOCK_EX, func() error {
+		records, err := r.read()
+		if err != nil {
+			return err
+		}
+
+		if _, found := records[strconv.Itoa(id)]; !found {
+			return nil
+		}
+
+		delete(records, strconv.Itoa(id))
+
+		return r.write(records)
+	})
+}
+
+func (r FileRegistry) withLock(how int, fn func() error) error {
+	err := os.MkdirAll(filepath.Dir(r.lockPath), 0755)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating stemcell registry directory")
+	}
+
+	lockFile, err := os.OpenFile(r.lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening stemcell registry lock '%s'", r.lockPath)
+	}
+	defer lockFile.Close()
+
+	err = syscall.Flock(int(lockFile.Fd()), how)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Locking stemcell registry lock '%s'", r.lockPath)
+	}
+	defer syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+func (r FileRegistry) read() (map[string]RegistryRecord, error) {
+	records := map[string]RegistryRecord{}
+
+	contents, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading stemcell registry '%s'", r.path)
+	}
+
+	err = json.Unmarshal(contents, &records)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Unmarshalling stemcell registry '%s'", r.path)
+	}
+
+	return records, nil
+}
+
+// write replaces the registry through a rename so that it is never left
+// half written
+func (r FileRegistry) write(records map[string]RegistryRecord) error {
+	contents, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling stemcell registry")
+	}
+
+	tmpPath := r.path + ".tmp"
+
+	err = ioutil.WriteFile(tmpPath, contents, 0644)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing stemcell registry '%s'", tmpPath)
+	}
+
+	err = os.Rename(tmpPath, r.path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming stemcell registry '%s'", tmpPath)
+	}
+
+	r.logger.Debug(fileRegistryLogTag, "Wrote %d stemcell record(s) to '%s'", len(records), r.path)
+
+	return nil
+}
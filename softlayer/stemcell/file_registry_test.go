@@ -0,0 +1,128 @@
+package stemcell_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+var _ = Describe("FileRegistry", func() {
+	var (
+		stemcellsDir string
+		registry     FileRegistry
+		record       RegistryRecord
+	)
+
+	BeforeEach(func() {
+		var err error
+		stemcellsDir, err = ioutil.TempDir("", "stemcells")
+		Expect(err).ToNot(HaveOccurred())
+
+		registry = NewFileRegistry(stemcellsDir, boshlog.NewLogger(boshlog.LevelNone))
+
+		record = RegistryRecord{
+			Kind:    VirtualGuestDeviceTemplateGroupKind,
+			Uuid:    "fake-uuid",
+			Name:    "fake-name",
+			Version: "fake-version",
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(stemcellsDir)
+	})
+
+	It("does not find a stemcell before any is saved", func() {
+		_, found, err := registry.Find(1234)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("finds a saved stemcell", func() {
+		Expect(registry.Save(1234, record)).To(Succeed())
+
+		foundRecord, found, err := registry.Find(1234)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(foundRecord.RecordedAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+		foundRecord.RecordedAt = time.Time{}
+		Expect(foundRecord).To(Equal(record))
+	})
+
+	It("keeps the records in a JSON file keyed by stemcell CID", func() {
+		Expect(registry.Save(1234, record)).To(Succeed())
+
+		contents, err := ioutil.ReadFile(filepath.Join(stemcellsDir, "registry.json"))
+		Expect(err).ToNot(HaveOccurred())
+
+		var records map[string]map[string]string
+		Expect(json.Unmarshal(contents, &records)).To(Succeed())
+		Expect(records).To(HaveKey("1234"))
+		Expect(records["1234"]).To(HaveKey("recorded_at"))
+
+		delete(records["1234"], "recorded_at")
+		Expect(records["1234"]).To(Equal(map[string]string{
+			"kind":    "VirtualGuestDeviceTemplateGroup",
+			"uuid":    "fake-uuid",
+			"name":    "fake-name",
+			"version": "fake-version",
+		}))
+	})
+
+	It("does not find a deleted stemcell", func() {
+		Expect(registry.Save(1234, record)).To(Succeed())
+		Expect(registry.Save(5678, record)).To(Succeed())
+		Expect(registry.Delete(1234)).To(Succeed())
+
+		_, found, err := registry.Find(1234)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+
+		_, found, err = registry.Find(5678)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+	})
+
+	It("does not fail deleting a stemcell that was never saved", func() {
+		Expect(registry.Delete(1234)).To(Succeed())
+	})
+
+	It("returns an error when the registry cannot be unmarshalled", func() {
+		Expect(ioutil.WriteFile(filepath.Join(stemcellsDir, "registry.json"), []byte("{"), 0644)).To(Succeed())
+
+		_, _, err := registry.Find(1234)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling stemcell registry"))
+	})
+
+	It("does not lose records saved concurrently", func() {
+		var wg sync.WaitGroup
+		for id := 1; id <= 20; id++ {
+			wg.Add(1)
+			go func(id int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				Expect(NewFileRegistry(stemcellsDir, boshlog.NewLogger(boshlog.LevelNone)).Save(id, record)).To(Succeed())
+			}(id)
+		}
+		wg.Wait()
+
+		for id := 1; id <= 20; id++ {
+			_, found, err := registry.Find(id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+		}
+	})
+})
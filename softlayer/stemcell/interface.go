@@ -1,14 +1,63 @@
 package stemcell
 
+import (
+	"io"
+	"time"
+)
+
+type Creator interface {
+	Create(imagePath string, metadata ImageMetadata) (Stemcell, error)
+}
+
 type Finder interface {
 	Find(uuid string) (Stemcell, bool, error)
 	FindById(id int) (Stemcell, bool, error)
 }
 
+// Registry records what the CPI knows about each stemcell it created or found,
+// so that it does not have to be looked up on SoftLayer every time
+type Registry interface {
+	// Save stamps the record with the time it was saved
+	Save(id int, record RegistryRecord) error
+	Find(id int) (RegistryRecord, bool, error)
+	Delete(id int) error
+}
+
+type RegistryRecord struct {
+	Kind string `json:"kind"`
+	Uuid string `json:"uuid"`
+
+	Datacenters []string `json:"datacenters,omitempty"`
+
+	// Stemcell name and version from the manifest
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
 type Stemcell interface {
 	ID() int
 	Uuid() string
 	Kind() string
 
+	// Datacenters returns the names of the datacenters the stemcell can be
+	// used in
+	Datacenters() ([]string, error)
+
 	Delete() error
 }
+
+type ImageUploader interface {
+	// Upload stores the image of the given size under the given name and
+	// returns the URI SoftLayer should import it from
+	Upload(name string, image io.Reader, size int64) (string, error)
+}
+
+type ImageMetadata struct {
+	Name    string
+	Version string
+
+	// e.g. UBUNTU_64
+	OperatingSystemReferenceCode string
+}
@@ -0,0 +1,84 @@
+package stemcell
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
+const (
+	defaultObjectStorageContainer = "bosh-stemcells"
+
+	// Swift rejects objects larger than 5GB; larger images are uploaded in
+	// segments tied together by a static large object manifest
+	maxObjectSize      = int64(5) << 30
+	defaultSegmentSize = int64(1) << 30
+)
+
+type ObjectStorageOptions struct {
+	// e.g. "https://dal05.objectstorage.softlayer.net/auth/v1.0"
+	AuthEndpoint string
+
+	// e.g. "SLOS123456-2:SL123456"
+	Username string
+	ApiKey   string
+
+	// Object storage cluster the endpoint belongs to, e.g. "dal05"
+	Cluster string
+
+	// Defaults to "bosh-stemcells"
+	Container string
+
+	// Size in bytes of the segments images larger than it are split into;
+	// defaults to 1GB and cannot exceed 5GB
+	SegmentSize int64
+}
+
+func (o ObjectStorageOptions) Validate() error {
+	if o.AuthEndpoint == "" {
+		return bosherr.Error("Must provide non-empty AuthEndpoint")
+	}
+
+	if o.Username == "" {
+		return bosherr.Error("Must provide non-empty Username")
+	}
+
+	if o.ApiKey == "" {
+		return bosherr.Error("Must provide non-empty ApiKey")
+	}
+
+	if o.Cluster == "" {
+		return bosherr.Error("Must provide non-empty Cluster")
+	}
+
+	if o.SegmentSize < 0 || o.SegmentSize > maxObjectSize {
+		return bosherr.Errorf("SegmentSize must be between 0 and %d bytes", maxObjectSize)
+	}
+
+	return nil
+}
+
+func (o ObjectStorageOptions) AccountName() string {
+	return strings.Split(o.Username, ":")[0]
+}
+
+func (o ObjectStorageOptions) ContainerName() string {
+	if o.Container == "" {
+		return defaultObjectStorageContainer
+	}
+
+	return o.Container
+}
+
+// segmentsContainerName is where the segments of large images are uploaded
+func (o ObjectStorageOptions) segmentsContainerName() string {
+	return o.ContainerName() + "_segments"
+}
+
+func (o ObjectStorageOptions) segmentSize() int64 {
+	if o.SegmentSize == 0 {
+		return defaultSegmentSize
+	}
+
+	return o.SegmentSize
+}
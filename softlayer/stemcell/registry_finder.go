@@ -0,0 +1,136 @@
+package stemcell
+
+import (
+	"encoding/json"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	sl "github.com/maximilien/softlayer-go/softlayer"
+)
+
+const registryFinderLogTag = "RegistryFinder"
+
+// CacheOptions controls how long stemcells recorded in the registry are
+// used without being looked up on SoftLayer again
+type CacheOptions struct {
+	// Every stemcell is looked up on SoftLayer when TTL is not set
+	TTL time.Duration
+}
+
+var DefaultCacheOptions = CacheOptions{TTL: 24 * time.Hour}
+
+type cacheOptionsJSON struct {
+	TTL string
+}
+
+// UnmarshalJSON reads TTL as a duration string such as "1h"
+func (o *CacheOptions) UnmarshalJSON(data []byte) error {
+	var options cacheOptionsJSON
+
+	err := json.Unmarshal(data, &options)
+	if err != nil {
+		return err
+	}
+
+	*o = CacheOptions{}
+
+	if options.TTL != "" {
+		o.TTL, err = time.ParseDuration(options.TTL)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing TTL '%s'", options.TTL)
+		}
+	}
+
+	return nil
+}
+
+// WithDefaults returns the options with every unset value taken from defaults
+func (o CacheOptions) WithDefaults(defaults CacheOptions) CacheOptions {
+	if o.TTL == 0 {
+		o.TTL = defaults.TTL
+	}
+
+	return o
+}
+
+// RegistryFinder finds stemcells recorded in the registry less than the TTL
+// ago without asking SoftLayer. Any other stemcell is looked up with the
+// given finder and its record is refreshed, or removed once the stemcell no
+// longer exists, so that stemcells deleted out of band are not found forever.
+type RegistryFinder struct {
+	finder   Finder
+	registry Registry
+	ttl      time.Duration
+
+	client sl.Client
+	logger boshlog.Logger
+}
+
+func NewRegistryFinder(finder Finder, registry Registry, options CacheOptions, client sl.Client, logger boshlog.Logger) RegistryFinder {
+	return RegistryFinder{
+		finder:   finder,
+		registry: registry,
+		ttl:      options.TTL,
+
+		client: client,
+		logger: logger,
+	}
+}
+
+func (f RegistryFinder) Find(uuid string) (Stemcell, bool, error) {
+	return f.finder.Find(uuid)
+}
+
+// FindById does not fail when the registry cannot be read or written since
+// the stemcell can still be looked up on SoftLayer
+func (f RegistryFinder) FindById(id int) (Stemcell, bool, error) {
+	record, recorded, err := f.registry.Find(id)
+	if err != nil {
+		f.logger.Warn(registryFinderLogTag, "Finding stemcell '%d' in registry: %s", id, err)
+	}
+
+	if recorded && time.Since(record.RecordedAt) < f.ttl {
+		f.logger.Debug(registryFinderLogTag, "Using stemcell '%d' recorded at %s", id, record.RecordedAt)
+		return NewSoftLayerStemcell(id, record.Uuid, record.Kind, f.client, f.logger), true, nil
+	}
+
+	stemcell, found, err := f.finder.FindById(id)
+	if err != nil {
+		return stemcell, found, err
+	}
+
+	if !found {
+		if recorded {
+			f.forget(id)
+		}
+
+		return stemcell, found, nil
+	}
+
+	// Keeps the manifest name and version of stemcells created by the CPI
+	record.Kind = stemcell.Kind()
+	record.Uuid = stemcell.Uuid()
+
+	record.Datacenters, err = stemcell.Datacenters()
+	if err != nil {
+		f.logger.Warn(registryFinderLogTag, "Getting datacenters of stemcell '%d': %s", id, err)
+	}
+
+	err = f.registry.Save(id, record)
+	if err != nil {
+		f.logger.Warn(registryFinderLogTag, "Recording stemcell '%d' in registry: %s", id, err)
+	}
+
+	return stemcell, found, nil
+}
+
+func (f RegistryFinder) forget(id int) {
+	f.logger.Debug(registryFinderLogTag, "Removing stemcell '%d' that no longer exists from registry", id)
+
+	err := f.registry.Delete(id)
+	if err != nil {
+		f.logger.Warn(registryFinderLogTag, "Removing stemcell '%d' from registry: %s", id, err)
+	}
+}
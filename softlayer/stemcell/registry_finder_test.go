@@ -0,0 +1,143 @@
+package stemcell_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
+	fakesslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("RegistryFinder", func() {
+	var (
+		softLayerClient *fakesslclient.FakeSoftLayerClient
+		finder          *fakestem.FakeFinder
+		registry        *fakestem.FakeRegistry
+		logger          boshlog.Logger
+		registryFinder  RegistryFinder
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakesslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		foundStemcell := fakestem.NewFakeStemcell(1234, "fake-found-uuid", VirtualDiskImageKind)
+		foundStemcell.DatacentersResult = []string{"ams01"}
+
+		finder = &fakestem.FakeFinder{
+			FindStemcell: foundStemcell,
+			FindFound:    true,
+		}
+		registry = fakestem.NewFakeRegistry()
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+
+		registryFinder = NewRegistryFinder(finder, registry, CacheOptions{TTL: 1 * time.Hour}, softLayerClient, logger)
+	})
+
+	Describe("FindById", func() {
+		It("returns a stemcell recorded less than the TTL ago without looking it up", func() {
+			registry.Records[1234] = RegistryRecord{Kind: VirtualGuestDeviceTemplateGroupKind, Uuid: "fake-uuid", RecordedAt: time.Now()}
+
+			stemcell, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(stemcell).To(Equal(NewSoftLayerStemcell(1234, "fake-uuid", VirtualGuestDeviceTemplateGroupKind, softLayerClient, logger)))
+			Expect(finder.FindID).To(Equal(0))
+		})
+
+		It("looks up a stemcell that is not recorded and records it", func() {
+			stemcell, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(stemcell.Uuid()).To(Equal("fake-found-uuid"))
+			Expect(finder.FindID).To(Equal(1234))
+
+			Expect(registry.Records).To(Equal(map[int]RegistryRecord{
+				1234: RegistryRecord{Kind: VirtualDiskImageKind, Uuid: "fake-found-uuid", Datacenters: []string{"ams01"}},
+			}))
+		})
+
+		It("looks up a stemcell recorded more than the TTL ago and keeps its manifest name and version", func() {
+			registry.Records[1234] = RegistryRecord{
+				Kind:       VirtualDiskImageKind,
+				Uuid:       "fake-uuid",
+				Name:       "fake-name",
+				Version:    "fake-version",
+				RecordedAt: time.Now().Add(-2 * time.Hour),
+			}
+
+			stemcell, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(stemcell.Uuid()).To(Equal("fake-found-uuid"))
+			Expect(finder.FindID).To(Equal(1234))
+
+			Expect(registry.Records[1234].Uuid).To(Equal("fake-found-uuid"))
+			Expect(registry.Records[1234].Datacenters).To(Equal([]string{"ams01"}))
+			Expect(registry.Records[1234].Name).To(Equal("fake-name"))
+			Expect(registry.Records[1234].Version).To(Equal("fake-version"))
+		})
+
+		It("looks up every stemcell when no TTL is set", func() {
+			registryFinder = NewRegistryFinder(finder, registry, CacheOptions{}, softLayerClient, logger)
+			registry.Records[1234] = RegistryRecord{Kind: VirtualDiskImageKind, Uuid: "fake-uuid", RecordedAt: time.Now()}
+
+			_, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(finder.FindID).To(Equal(1234))
+		})
+
+		It("removes the record of a stemcell that no longer exists", func() {
+			registry.Records[1234] = RegistryRecord{Kind: VirtualDiskImageKind, Uuid: "fake-uuid", RecordedAt: time.Now().Add(-2 * time.Hour)}
+			finder.FindFound = false
+			finder.FindStemcell = nil
+
+			_, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(registry.Records).To(BeEmpty())
+		})
+
+		It("keeps the record when looking up the stemcell fails", func() {
+			registry.Records[1234] = RegistryRecord{Kind: VirtualDiskImageKind, Uuid: "fake-uuid", RecordedAt: time.Now().Add(-2 * time.Hour)}
+			finder.FindErr = errors.New("fake-find-err")
+
+			_, _, err := registryFinder.FindById(1234)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-find-err"))
+			Expect(registry.Records).To(HaveKey(1234))
+		})
+
+		It("looks up the stemcell when the registry cannot be read or written", func() {
+			registry.FindErr = errors.New("fake-find-err")
+			registry.SaveErr = errors.New("fake-save-err")
+
+			_, found, err := registryFinder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(finder.FindID).To(Equal(1234))
+		})
+	})
+})
+
+var _ = Describe("CacheOptions", func() {
+	It("reads TTL as a duration string", func() {
+		var options CacheOptions
+
+		err := json.Unmarshal([]byte(`{"TTL": "30m"}`), &options)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(Equal(CacheOptions{TTL: 30 * time.Minute}))
+	})
+
+	It("takes an unset TTL from the defaults", func() {
+		Expect(CacheOptions{}.WithDefaults(DefaultCacheOptions)).To(Equal(DefaultCacheOptions))
+		Expect(CacheOptions{TTL: time.Minute}.WithDefaults(DefaultCacheOptions)).To(Equal(CacheOptions{TTL: time.Minute}))
+	})
+})
@@ -0,0 +1,186 @@
+package stemcell
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+)
+
+const (
+	softLayerCreatorLogTag = "SoftLayerCreator"
+
+	defaultOperatingSystemReferenceCode = "UBUNTU_64"
+	rootImageExtension                  = ".vhd"
+)
+
+type SoftLayerCreator struct {
+	softLayerClient sl.Client
+	uploader        ImageUploader
+
+	waitOptions bslcommon.WaitOptions
+	logger      boshlog.Logger
+}
+
+type externalSourceConfiguration struct {
+	Name                         string `json:"name"`
+	Note                         string `json:"note,omitempty"`
+	OperatingSystemReferenceCode string `json:"operatingSystemReferenceCode"`
+	Uri                          string `json:"uri"`
+}
+
+func NewSoftLayerCreator(softLayerClient sl.Client, uploader ImageUploader, waitOptions bslcommon.WaitOptions, logger boshlog.Logger) SoftLayerCreator {
+	return SoftLayerCreator{
+		softLayerClient: softLayerClient,
+		uploader:        uploader,
+		waitOptions:     waitOptions,
+		logger:          logger,
+	}
+}
+
+func (c SoftLayerCreator) Create(imagePath string, metadata ImageMetadata) (Stemcell, error) {
+	image, size, err := openRootImage(imagePath)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening root image from '%s'", imagePath)
+	}
+	defer image.Close()
+
+	name := metadata.Name
+	if name == "" {
+		name = "bosh-stemcell"
+	}
+
+	if metadata.Version != "" {
+		name = fmt.Sprintf("%s-%s", name, metadata.Version)
+	}
+
+	uri, err := c.uploader.Upload(name+rootImageExtension, image, size)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Uploading root image '%s'", name)
+	}
+
+	osRefCode := metadata.OperatingSystemReferenceCode
+	if osRefCode == "" {
+		osRefCode = defaultOperatingSystemReferenceCode
+	}
+
+	templateGroup, err := c.createFromExternalSource(externalSourceConfiguration{
+		Name:                         name,
+		Note:                         "Created by the BOSH SoftLayer CPI",
+		OperatingSystemReferenceCode: osRefCode,
+		Uri:                          uri,
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating VirtualGuestBlockDeviceTemplateGroup from '%s'", uri)
+	}
+
+	c.logger.Debug(softLayerCreatorLogTag, "Waiting for VirtualGuestBlockDeviceTemplateGroup `%d` to be active", templateGroup.Id)
+
+	err = bslcommon.WaitForVirtualGuestBlockDeviceTemplateGroup(c.softLayerClient, templateGroup.Id, "ACTIVE", c.waitOptions)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Waiting for VirtualGuestBlockDeviceTemplateGroup `%d` to be active", templateGroup.Id)
+	}
+
+	return NewSoftLayerStemcell(templateGroup.Id, templateGroup.GlobalIdentifier, VirtualGuestDeviceTemplateGroupKind, c.softLayerClient, c.logger), nil
+}
+
+func (c SoftLayerCreator) createFromExternalSource(configuration externalSourceConfiguration) (sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group, error) {
+	parameters := map[string]interface{}{
+		"parameters": []externalSourceConfiguration{configuration},
+	}
+
+	requestBody, err := json.Marshal(parameters)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}, bosherr.WrapError(err, "Marshalling external source configuration")
+	}
+
+	response, err := c.softLayerClient.DoRawHttpRequest("SoftLayer_Virtual_Guest_Block_Device_Template_Group/createFromExternalSource.json", "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}, err
+	}
+
+	err = c.softLayerClient.CheckForHttpResponseErrors(response)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}, err
+	}
+
+	templateGroup := sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}
+	err = json.Unmarshal(response, &templateGroup)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}, bosherr.WrapError(err, "Unmarshalling VirtualGuestBlockDeviceTemplateGroup")
+	}
+
+	if templateGroup.Id == 0 {
+		return sldatatypes.SoftLayer_Virtual_Guest_Block_Device_Template_Group{}, bosherr.Errorf("Unexpected response '%s' from the API", string(response))
+	}
+
+	return templateGroup, nil
+}
+
+type rootImage struct {
+	io.Reader
+
+	file *os.File
+}
+
+func (i rootImage) Close() error { return i.file.Close() }
+
+// openRootImage returns the root image itself when imagePath points to it,
+// or the first root image found inside imagePath when it is a tgz, together
+// with its size
+func openRootImage(imagePath string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, 0, bosherr.WrapError(err, "Opening image")
+	}
+
+	if strings.HasSuffix(imagePath, rootImageExtension) {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, bosherr.WrapError(err, "Reading image size")
+		}
+
+		return file, info.Size(), nil
+	}
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, bosherr.WrapError(err, "Reading image as gzip")
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			file.Close()
+			return nil, 0, bosherr.WrapError(err, "Reading image as tar")
+		}
+
+		if header.Typeflag == tar.TypeReg && filepath.Ext(header.Name) == rootImageExtension {
+			return rootImage{Reader: tarReader, file: file}, header.Size, nil
+		}
+	}
+
+	file.Close()
+
+	return nil, 0, bosherr.Errorf("Expected to find a '%s' root image", rootImageExtension)
+}
@@ -0,0 +1,138 @@
+package stemcell_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
+	fakesslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("SoftLayerCreator", func() {
+	var (
+		softLayerClient *fakesslclient.FakeSoftLayerClient
+		uploader        *fakestem.FakeImageUploader
+		logger          boshlog.Logger
+		creator         SoftLayerCreator
+
+		tmpDir    string
+		imagePath string
+		metadata  ImageMetadata
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakesslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		uploader = &fakestem.FakeImageUploader{UploadURI: "swift://fake-account@fake-cluster/bosh-stemcells/fake-name-fake-version.vhd"}
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		waitOptions := bslcommon.WaitOptions{Timeout: 1 * time.Second, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+		creator = NewSoftLayerCreator(softLayerClient, uploader, waitOptions, logger)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "softlayer-creator-test")
+		Expect(err).ToNot(HaveOccurred())
+
+		imagePath = filepath.Join(tmpDir, "image")
+		writeImageTarball(imagePath, "root.vhd", "fake-root-image")
+
+		metadata = ImageMetadata{Name: "fake-name", Version: "fake-version"}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("Create", func() {
+		Context("when the template group becomes active", func() {
+			BeforeEach(func() {
+				fileNames := []string{
+					"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_createFromExternalSource.json",
+					"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getStatus_Creating.json",
+					"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getStatus.json",
+				}
+				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+			})
+
+			It("uploads the root image and returns the created stemcell", func() {
+				stemcell, err := creator.Create(imagePath, metadata)
+				Expect(err).ToNot(HaveOccurred())
+
+				expectedStemcell := NewSoftLayerStemcell(5678, "fake-global-identifier", VirtualGuestDeviceTemplateGroupKind, softLayerClient, logger)
+				Expect(stemcell).To(Equal(expectedStemcell))
+
+				Expect(uploader.UploadName).To(Equal("fake-name-fake-version.vhd"))
+				Expect(string(uploader.UploadContents)).To(Equal("fake-root-image"))
+				Expect(uploader.UploadSize).To(Equal(int64(len("fake-root-image"))))
+			})
+		})
+
+		Context("when the image does not contain a root image", func() {
+			BeforeEach(func() {
+				writeImageTarball(imagePath, "fake-file", "fake-contents")
+			})
+
+			It("returns error", func() {
+				_, err := creator.Create(imagePath, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Expected to find a '.vhd' root image"))
+			})
+		})
+
+		Context("when uploading the root image fails", func() {
+			BeforeEach(func() {
+				uploader.UploadErr = errors.New("fake-upload-err")
+			})
+
+			It("returns error", func() {
+				_, err := creator.Create(imagePath, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-upload-err"))
+			})
+		})
+
+		Context("when creating the template group fails", func() {
+			BeforeEach(func() {
+				softLayerClient.DoRawHttpRequestError = errors.New("fake-create-err")
+			})
+
+			It("returns error", func() {
+				_, err := creator.Create(imagePath, metadata)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-create-err"))
+			})
+		})
+	})
+})
+
+func writeImageTarball(path, fileName, contents string) {
+	file, err := os.Create(path)
+	Expect(err).ToNot(HaveOccurred())
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	defer gzipWriter.Close()
+
+	tarWriter := tar.NewWriter(gzipWriter)
+	defer tarWriter.Close()
+
+	err = tarWriter.WriteHeader(&tar.Header{Name: fileName, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+	Expect(err).ToNot(HaveOccurred())
+
+	_, err = tarWriter.Write([]byte(contents))
+	Expect(err).ToNot(HaveOccurred())
+}
@@ -4,6 +4,8 @@ import (
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+
 	sl "github.com/maximilien/softlayer-go/softlayer"
 )
 
@@ -16,13 +18,11 @@ func NewSoftLayerFinder(client sl.Client, logger boshlog.Logger) SoftLayerFinder
 	return SoftLayerFinder{client: client, logger: logger}
 }
 
+// FindById looks the stemcell up directly among virtual disk images and then
+// among block device template groups. Only an empty object or a not found
+// fault means that there is no such ID; any other fault is an error.
 func (f SoftLayerFinder) FindById(id int) (Stemcell, bool, error) {
-	accountService, err := f.client.GetSoftLayer_Account_Service()
-	if err != nil {
-		return nil, false, bosherr.WrapError(err, "Getting SoftLayer AccountService")
-	}
-
-	stemcell, found, err := f.findByIdInVirtualDiskImages(id, accountService)
+	stemcell, found, err := f.findByIdInVirtualDiskImages(id)
 	if err != nil {
 		return stemcell, found, err
 	}
@@ -30,7 +30,7 @@ func (f SoftLayerFinder) FindById(id int) (Stemcell, bool, error) {
 	if found {
 		return stemcell, found, nil
 	} else {
-		stemcell, found, err = f.findByIdInVirtualGuestDeviceTemplateGroups(id, accountService)
+		stemcell, found, err = f.findByIdInVirtualGuestDeviceTemplateGroups(id)
 		if err != nil {
 			return stemcell, found, err
 		}
@@ -77,19 +77,26 @@ func (f SoftLayerFinder) findInVirtualDiskImages(uuid string, accountService sl.
 	return nil, false, nil
 }
 
-func (f SoftLayerFinder) findByIdInVirtualDiskImages(id int, accountService sl.SoftLayer_Account_Service) (Stemcell, bool, error) {
-	virtualDiskImages, err := accountService.GetVirtualDiskImages()
+func (f SoftLayerFinder) findByIdInVirtualDiskImages(id int) (Stemcell, bool, error) {
+	vdImageService, err := bslcommon.NewFaultDetectingClient(f.client).GetSoftLayer_Virtual_Disk_Image_Service()
 	if err != nil {
-		return nil, false, bosherr.WrapError(err, "Getting virtual disk images")
+		return nil, false, bosherr.WrapError(err, "Getting SoftLayer VirtualDiskImageService")
 	}
 
-	for _, vdImage := range virtualDiskImages {
-		if vdImage.Id == id {
-			return NewSoftLayerStemcell(vdImage.Id, vdImage.Uuid, VirtualDiskImageKind, f.client, f.logger), true, nil
+	vdImage, err := vdImageService.GetObject(id)
+	if err != nil {
+		if _, ok := err.(bslcommon.ObjectNotFoundError); ok {
+			return nil, false, nil
 		}
+
+		return nil, false, bosherr.WrapErrorf(err, "Getting virtual disk image with ID '%d'", id)
 	}
 
-	return nil, false, nil
+	if vdImage.Id != id {
+		return nil, false, nil
+	}
+
+	return NewSoftLayerStemcell(vdImage.Id, vdImage.Uuid, VirtualDiskImageKind, f.client, f.logger), true, nil
 }
 
 func (f SoftLayerFinder) findInVirtualGuestDeviceTemplateGroups(uuid string, accountService sl.SoftLayer_Account_Service) (Stemcell, bool, error) {
@@ -107,17 +114,24 @@ func (f SoftLayerFinder) findInVirtualGuestDeviceTemplateGroups(uuid string, acc
 	return nil, false, nil
 }
 
-func (f SoftLayerFinder) findByIdInVirtualGuestDeviceTemplateGroups(id int, accountService sl.SoftLayer_Account_Service) (Stemcell, bool, error) {
-	vgdtgGroups, err := accountService.GetBlockDeviceTemplateGroups()
+func (f SoftLayerFinder) findByIdInVirtualGuestDeviceTemplateGroups(id int) (Stemcell, bool, error) {
+	vgdtgService, err := bslcommon.NewFaultDetectingClient(f.client).GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
 	if err != nil {
-		return nil, false, bosherr.WrapError(err, "Getting virtual guest device template groups")
+		return nil, false, bosherr.WrapError(err, "Getting SoftLayer VirtualGuestBlockDeviceTemplateGroupService")
 	}
 
-	for _, vgdtgGroup := range vgdtgGroups {
-		if vgdtgGroup.Id == id {
-			return NewSoftLayerStemcell(vgdtgGroup.Id, vgdtgGroup.GlobalIdentifier, VirtualGuestDeviceTemplateGroupKind, f.client, f.logger), true, nil
+	vgdtgGroup, err := vgdtgService.GetObject(id)
+	if err != nil {
+		if _, ok := err.(bslcommon.ObjectNotFoundError); ok {
+			return nil, false, nil
 		}
+
+		return nil, false, bosherr.WrapErrorf(err, "Getting virtual guest device template group with ID '%d'", id)
 	}
 
-	return nil, false, nil
+	if vgdtgGroup.Id != id {
+		return nil, false, nil
+	}
+
+	return NewSoftLayerStemcell(vgdtgGroup.Id, vgdtgGroup.GlobalIdentifier, VirtualGuestDeviceTemplateGroupKind, f.client, f.logger), true, nil
 }
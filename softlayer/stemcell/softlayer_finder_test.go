@@ -1,6 +1,8 @@
 package stemcell_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -49,4 +51,67 @@ var _ = Describe("SoftLayerFinder", func() {
 			})
 		})
 	})
+	Describe("FindById", func() {
+		BeforeEach(func() {
+			softLayerClient.DoRawHttpRequestResponse = nil
+		})
+
+		It("returns the virtual disk image with the given ID", func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Disk_Image_Service_getObject.json"})
+
+			stemcell, found, err := finder.FindById(4868344)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			expectedStemcell := NewSoftLayerStemcell(4868344, "8c7a8358-d9a9-4e4d-9345-6f637e10ccb7", VirtualDiskImageKind, softLayerClient, logger)
+			Expect(stemcell).To(Equal(expectedStemcell))
+		})
+
+		It("returns the virtual guest device template group with the given ID when there is no such virtual disk image", func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{
+				"SoftLayer_Virtual_Disk_Image_Service_getEmptyObject.json",
+				"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getObject.json",
+			})
+
+			stemcell, found, err := finder.FindById(211582)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			expectedStemcell := NewSoftLayerStemcell(211582, "4e0aba84-8b8e-4d64-a1b4-1a22c5a5f1be", VirtualGuestDeviceTemplateGroupKind, softLayerClient, logger)
+			Expect(stemcell).To(Equal(expectedStemcell))
+		})
+
+		It("returns found as false when neither kind has the given ID", func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{
+				"SoftLayer_Virtual_Disk_Image_Service_getEmptyObject.json",
+				"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getEmptyObject.json",
+			})
+
+			stemcell, found, err := finder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(stemcell).To(BeNil())
+		})
+
+		It("returns found as false when SoftLayer answers with not found faults for both kinds", func() {
+			notFound := []byte(`{"error": "Unable to find object with id of '1234'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{notFound, notFound}
+
+			stemcell, found, err := finder.FindById(1234)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(stemcell).To(BeNil())
+		})
+
+		It("returns an error when SoftLayer answers with any other fault", func() {
+			softLayerClient.DoRawHttpRequestResponses = [][]byte{[]byte(`{"error": "fake-fault", "code": "SoftLayer_Exception_Public"}`)}
+			softLayerClient.CheckForHttpResponseError = errors.New("fake-fault")
+
+			stemcell, found, err := finder.FindById(1234)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-fault"))
+			Expect(found).To(BeFalse())
+			Expect(stemcell).To(BeNil())
+		})
+	})
 })
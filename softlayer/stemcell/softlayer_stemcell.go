@@ -1,11 +1,16 @@
 package stemcell
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
 )
 
@@ -41,16 +46,213 @@ func (s SoftLayerStemcell) Uuid() string { return s.uuid }
 
 func (s SoftLayerStemcell) Kind() string { return s.kind }
 
+// Datacenters returns the names of the datacenters a template group was
+// copied to, or the datacenters of the storage repository an image is in
+func (s SoftLayerStemcell) Datacenters() ([]string, error) {
+	var locations []sldatatypes.SoftLayer_Location
+
+	switch s.kind {
+	case VirtualGuestDeviceTemplateGroupKind:
+		templateGroupService, err := s.softLayerClient.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Creating VirtualGuestBlockDeviceTemplateGroupService from SoftLayer client")
+		}
+
+		locations, err = templateGroupService.GetDatacenters(s.id)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Getting datacenters of VirtualGuestBlockDeviceTemplateGroup `%d`", s.id)
+		}
+	case VirtualDiskImageKind:
+		response, err := s.softLayerClient.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Virtual_Disk_Image/%d/getObject.json", s.id), []string{"storageRepository.datacenters.name"}, "GET", new(bytes.Buffer))
+		if err == nil {
+			err = s.softLayerClient.CheckForHttpResponseErrors(response)
+		}
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Getting datacenters of VirtualDiskImage `%d`", s.id)
+		}
+
+		image := struct {
+			StorageRepository struct {
+				Datacenters []sldatatypes.SoftLayer_Location `json:"datacenters"`
+			} `json:"storageRepository"`
+		}{}
+		err = json.Unmarshal(response, &image)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Unmarshalling VirtualDiskImage datacenters")
+		}
+
+		locations = image.StorageRepository.Datacenters
+	default:
+		return nil, bosherr.Errorf("Unknown SoftLayer stemcell kind '%s'", s.kind)
+	}
+
+	names := []string{}
+	for _, location := range locations {
+		names = append(names, location.Name)
+	}
+
+	return names, nil
+}
+
 func (s SoftLayerStemcell) Delete() error {
 	if s.kind == VirtualGuestDeviceTemplateGroupKind {
 		return s.deleteVirtualGuestDiskTemplateGroup(s.id)
 	} else if s.kind == VirtualDiskImageKind {
-		return nil
+		return s.deleteVirtualDiskImage(s.id)
 	} else {
 		return bosherr.WrapError(nil, "Unknown SoftLayer stemcell kind")
 	}
 }
 
+// ImageInUseError is returned when deleting a virtual disk image that
+// virtual guests still boot from, or that template groups, and so the
+// guests booted from them, are built on
+type ImageInUseError struct {
+	Id               int
+	VirtualGuestIds  []int
+	TemplateGroupIds []int
+}
+
+func (e ImageInUseError) Error() string {
+	users := []string{}
+
+	if len(e.VirtualGuestIds) > 0 {
+		users = append(users, "VirtualGuest(s) "+joinIds(e.VirtualGuestIds))
+	}
+
+	if len(e.TemplateGroupIds) > 0 {
+		users = append(users, "VirtualGuestBlockDeviceTemplateGroup(s) "+joinIds(e.TemplateGroupIds))
+	}
+
+	return fmt.Sprintf("VirtualDiskImage `%d` is still used by %s", e.Id, strings.Join(users, " and "))
+}
+
+func joinIds(ids []int) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// deleteVirtualDiskImage refuses to delete an image that is still in use
+// since the guests using it would lose their disk
+func (s SoftLayerStemcell) deleteVirtualDiskImage(id int) error {
+	guestIds, err := s.findVirtualGuestsUsingImage(id)
+	if err != nil {
+		return err
+	}
+
+	templateGroupIds, err := s.findTemplateGroupsUsingImage(id)
+	if err != nil {
+		return err
+	}
+
+	if len(guestIds) > 0 || len(templateGroupIds) > 0 {
+		return ImageInUseError{Id: id, VirtualGuestIds: guestIds, TemplateGroupIds: templateGroupIds}
+	}
+
+	response, err := s.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Disk_Image/%d.json", id), "DELETE", new(bytes.Buffer))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting VirtualDiskImage `%d`", id)
+	}
+
+	if res := string(response); res != "true" {
+		return bosherr.Errorf("Deleting VirtualDiskImage `%d`, got '%s' as response from the API", id, res)
+	}
+
+	return nil
+}
+
+type imageBlockDevice struct {
+	GuestId int `json:"guestId"`
+}
+
+func (s SoftLayerStemcell) findVirtualGuestsUsingImage(id int) ([]int, error) {
+	response, err := s.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Disk_Image/%d/getBlockDevices.json", id), "GET", new(bytes.Buffer))
+	if err == nil {
+		err = s.softLayerClient.CheckForHttpResponseErrors(response)
+	}
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting block devices of VirtualDiskImage `%d`", id)
+	}
+
+	blockDevices := []imageBlockDevice{}
+	err = json.Unmarshal(response, &blockDevices)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling block devices")
+	}
+
+	guestIds := []int{}
+	for _, blockDevice := range blockDevices {
+		if blockDevice.GuestId != 0 {
+			guestIds = append(guestIds, blockDevice.GuestId)
+		}
+	}
+
+	return guestIds, nil
+}
+
+// templateGroupImages holds the images a template group is built on. A
+// group replicated to other datacenters has a child per datacenter, which
+// holds the block devices there.
+type templateGroupImages struct {
+	Id           int `json:"id"`
+	BlockDevices []struct {
+		DiskImageId int `json:"diskImageId"`
+	} `json:"blockDevices"`
+	Children []templateGroupImages `json:"children"`
+}
+
+func (g templateGroupImages) usesImage(id int) bool {
+	for _, blockDevice := range g.BlockDevices {
+		if blockDevice.DiskImageId == id {
+			return true
+		}
+	}
+
+	for _, child := range g.Children {
+		if child.usesImage(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s SoftLayerStemcell) findTemplateGroupsUsingImage(id int) ([]int, error) {
+	objectMask := []string{
+		"id",
+		"blockDevices.diskImageId",
+		"children.id",
+		"children.blockDevices.diskImageId",
+	}
+
+	response, err := s.softLayerClient.DoRawHttpRequestWithObjectMask("SoftLayer_Account/getBlockDeviceTemplateGroups.json", objectMask, "GET", new(bytes.Buffer))
+	if err == nil {
+		err = s.softLayerClient.CheckForHttpResponseErrors(response)
+	}
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting VirtualGuestBlockDeviceTemplateGroups that may use VirtualDiskImage `%d`", id)
+	}
+
+	groups := []templateGroupImages{}
+	err = json.Unmarshal(response, &groups)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling VirtualGuestBlockDeviceTemplateGroups")
+	}
+
+	groupIds := []int{}
+	for _, group := range groups {
+		if group.usesImage(id) {
+			groupIds = append(groupIds, group.Id)
+		}
+	}
+
+	return groupIds, nil
+}
+
 func (s SoftLayerStemcell) deleteVirtualGuestDiskTemplateGroup(id int) error {
 	vgdtgService, err := s.softLayerClient.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
 	if err != nil {
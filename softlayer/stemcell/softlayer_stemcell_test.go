@@ -8,6 +8,8 @@ import (
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+
 	fakesslclient "github.com/maximilien/softlayer-go/client/fakes"
 )
 
@@ -26,6 +28,25 @@ var _ = Describe("SoftLayerStemcell", func() {
 		stemcell = NewSoftLayerStemcell(1234, "fake-stemcell-uuid", DefaultKind, softLayerClient, logger)
 	})
 
+	Describe("Datacenters", func() {
+		It("returns the datacenters a template group was copied to", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte(`[{"id": 265592, "name": "ams01"}, {"id": 138124, "name": "dal05"}]`)
+
+			datacenters, err := stemcell.Datacenters()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(datacenters).To(Equal([]string{"ams01", "dal05"}))
+		})
+
+		It("returns the datacenters of the storage repository of an image", func() {
+			stemcell = NewSoftLayerStemcell(1234, "fake-stemcell-uuid", VirtualDiskImageKind, softLayerClient, logger)
+			softLayerClient.DoRawHttpRequestResponse = []byte(`{"storageRepository": {"datacenters": [{"id": 265592, "name": "ams01"}]}}`)
+
+			datacenters, err := stemcell.Datacenters()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(datacenters).To(Equal([]string{"ams01"}))
+		})
+	})
+
 	Describe("Delete", func() {
 		BeforeEach(func() {
 			softLayerClient.DoRawHttpRequestResponse = []byte("true")
@@ -48,5 +69,65 @@ var _ = Describe("SoftLayerStemcell", func() {
 				Expect(err).To(HaveOccurred())
 			})
 		})
+
+		Context("when stemcell is a virtual disk image", func() {
+			BeforeEach(func() {
+				softLayerClient.DoRawHttpRequestResponse = nil
+				stemcell = NewSoftLayerStemcell(1234, "fake-stemcell-uuid", VirtualDiskImageKind, softLayerClient, logger)
+			})
+
+			It("deletes the image when nothing uses it", func() {
+				recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+				stemcell = NewSoftLayerStemcell(1234, "fake-stemcell-uuid", VirtualDiskImageKind, recordingClient, logger)
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{[]byte("[]"), []byte("[]"), []byte("true")}
+
+				err := stemcell.Delete()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(recordingClient.Requests).To(HaveLen(3))
+				Expect(recordingClient.Requests[0].Path).To(Equal("SoftLayer_Virtual_Disk_Image/1234/getBlockDevices.json"))
+				Expect(recordingClient.Requests[1].Path).To(Equal("SoftLayer_Account/getBlockDeviceTemplateGroups.json"))
+				Expect(recordingClient.Requests[1].ObjectMask).To(ContainElement("children.blockDevices.diskImageId"))
+				Expect(recordingClient.Requests[2].Path).To(Equal("SoftLayer_Virtual_Disk_Image/1234.json"))
+				Expect(recordingClient.Requests[2].RequestType).To(Equal("DELETE"))
+			})
+
+			It("returns an ImageInUseError without deleting the image when virtual guests use it", func() {
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{
+					[]byte(`[{"id": 1, "guestId": 5678}, {"id": 2, "guestId": 9012}]`),
+					[]byte("[]"),
+				}
+
+				err := stemcell.Delete()
+				Expect(err).To(Equal(ImageInUseError{Id: 1234, VirtualGuestIds: []int{5678, 9012}, TemplateGroupIds: []int{}}))
+				Expect(err.Error()).To(Equal("VirtualDiskImage `1234` is still used by VirtualGuest(s) 5678, 9012"))
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(2))
+			})
+
+			It("returns an ImageInUseError without deleting the image when template groups are built on it", func() {
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{
+					[]byte("[]"),
+					[]byte(`[
+						{"id": 42, "blockDevices": [{"diskImageId": 1234}]},
+						{"id": 43, "children": [{"id": 44, "blockDevices": [{"diskImageId": 1234}]}]},
+						{"id": 45, "blockDevices": [{"diskImageId": 5678}]}
+					]`),
+				}
+
+				err := stemcell.Delete()
+				Expect(err).To(Equal(ImageInUseError{Id: 1234, VirtualGuestIds: []int{}, TemplateGroupIds: []int{42, 43}}))
+				Expect(err.Error()).To(Equal("VirtualDiskImage `1234` is still used by VirtualGuestBlockDeviceTemplateGroup(s) 42, 43"))
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(2))
+			})
+
+			It("returns error if the image cannot be deleted", func() {
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{[]byte("[]"), []byte("[]"), []byte("false")}
+
+				err := stemcell.Delete()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Deleting VirtualDiskImage `1234`"))
+				Expect(err.Error()).To(ContainSubstring("got 'false'"))
+			})
+		})
 	})
 })
@@ -0,0 +1,178 @@
+package stemcell
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+const swiftUploaderLogTag = "SwiftUploader"
+
+// swiftClient bounds every request, i.e. the upload of at most one segment,
+// so that a stalled object storage endpoint cannot hang create_stemcell
+var swiftClient = &http.Client{
+	Timeout: 1 * time.Hour,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		Dial:                  (&net.Dialer{Timeout: 30 * time.Second}).Dial,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Minute,
+	},
+}
+
+type SwiftUploader struct {
+	options ObjectStorageOptions
+	client  *http.Client
+	logger  boshlog.Logger
+}
+
+type sloSegment struct {
+	Path      string `json:"path"`
+	Etag      string `json:"etag"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+func NewSwiftUploader(options ObjectStorageOptions, logger boshlog.Logger) SwiftUploader {
+	return SwiftUploader{options: options, client: swiftClient, logger: logger}
+}
+
+func (u SwiftUploader) Upload(name string, image io.Reader, size int64) (string, error) {
+	err := u.options.Validate()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Validating ObjectStorage configuration")
+	}
+
+	storageURL, token, err := u.authenticate()
+	if err != nil {
+		return "", bosherr.WrapError(err, "Authenticating with object storage")
+	}
+
+	containerURL := fmt.Sprintf("%s/%s", storageURL, u.options.ContainerName())
+
+	err = u.put(containerURL, token, nil, 0)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Creating container '%s'", u.options.ContainerName())
+	}
+
+	u.logger.Debug(swiftUploaderLogTag, "Uploading image '%s' of %d bytes to container '%s'", name, size, u.options.ContainerName())
+
+	if size > u.options.segmentSize() {
+		err = u.uploadSegmented(storageURL, token, name, image, size)
+	} else {
+		err = u.put(fmt.Sprintf("%s/%s", containerURL, name), token, image, size)
+	}
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Uploading image '%s'", name)
+	}
+
+	return fmt.Sprintf("swift://%s@%s/%s/%s", u.options.AccountName(), u.options.Cluster, u.options.ContainerName(), name), nil
+}
+
+// uploadSegmented uploads the image in segments to a separate container and
+// then stores a static large object manifest under the image name
+func (u SwiftUploader) uploadSegmented(storageURL string, token string, name string, image io.Reader, size int64) error {
+	segmentsContainer := u.options.segmentsContainerName()
+
+	err := u.put(fmt.Sprintf("%s/%s", storageURL, segmentsContainer), token, nil, 0)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating container '%s'", segmentsContainer)
+	}
+
+	segments := []sloSegment{}
+
+	for offset := int64(0); offset < size; offset += u.options.segmentSize() {
+		segmentSize := size - offset
+		if segmentSize > u.options.segmentSize() {
+			segmentSize = u.options.segmentSize()
+		}
+
+		path := fmt.Sprintf("/%s/%s/%08d", segmentsContainer, name, len(segments))
+
+		u.logger.Debug(swiftUploaderLogTag, "Uploading segment '%s' of %d bytes", path, segmentSize)
+
+		hash := md5.New()
+
+		err = u.put(storageURL+path, token, io.TeeReader(io.LimitReader(image, segmentSize), hash), segmentSize)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Uploading segment '%s'", path)
+		}
+
+		segments = append(segments, sloSegment{Path: path, Etag: hex.EncodeToString(hash.Sum(nil)), SizeBytes: segmentSize})
+	}
+
+	manifest, err := json.Marshal(segments)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling large object manifest")
+	}
+
+	manifestURL := fmt.Sprintf("%s/%s/%s?multipart-manifest=put", storageURL, u.options.ContainerName(), name)
+
+	err = u.put(manifestURL, token, bytes.NewReader(manifest), int64(len(manifest)))
+	if err != nil {
+		return bosherr.WrapError(err, "Uploading large object manifest")
+	}
+
+	return nil
+}
+
+func (u SwiftUploader) authenticate() (string, string, error) {
+	request, err := http.NewRequest("GET", u.options.AuthEndpoint, nil)
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Building authentication request")
+	}
+
+	request.Header.Set("X-Auth-User", u.options.Username)
+	request.Header.Set("X-Auth-Key", u.options.ApiKey)
+
+	response, err := u.client.Do(request)
+	if err != nil {
+		return "", "", bosherr.WrapError(err, "Performing authentication request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return "", "", bosherr.Errorf("Authentication failed with status '%s'", response.Status)
+	}
+
+	storageURL, token := response.Header.Get("X-Storage-Url"), response.Header.Get("X-Auth-Token")
+	if storageURL == "" || token == "" {
+		return "", "", bosherr.Error("Authentication response is missing storage URL or token")
+	}
+
+	return storageURL, token, nil
+}
+
+// put sends body with a known length so that Swift can tell a truncated
+// upload from a complete one
+func (u SwiftUploader) put(url string, token string, body io.Reader, size int64) error {
+	request, err := http.NewRequest("PUT", url, body)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Building PUT request for '%s'", url)
+	}
+
+	request.Header.Set("X-Auth-Token", token)
+	if body != nil {
+		request.ContentLength = size
+	}
+
+	response, err := u.client.Do(request)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Performing PUT request for '%s'", url)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		return bosherr.Errorf("PUT request for '%s' failed with status '%s'", url, response.Status)
+	}
+
+	return nil
+}
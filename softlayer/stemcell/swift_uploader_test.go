@@ -0,0 +1,185 @@
+package stemcell_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+)
+
+var _ = Describe("SwiftUploader", func() {
+	var (
+		server   *httptest.Server
+		options  ObjectStorageOptions
+		uploader SwiftUploader
+
+		uploadedPaths    []string
+		uploadedContents string
+		uploadedManifest string
+		authStatus       int
+	)
+
+	BeforeEach(func() {
+		uploadedPaths = []string{}
+		uploadedContents = ""
+		uploadedManifest = ""
+		authStatus = http.StatusOK
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/auth/v1.0" {
+				if r.Header.Get("X-Auth-User") != "SLOS123-2:SL123" || r.Header.Get("X-Auth-Key") != "fake-api-key" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				w.Header().Set("X-Storage-Url", "http://"+r.Host+"/v1/AUTH_fake")
+				w.Header().Set("X-Auth-Token", "fake-token")
+				w.WriteHeader(authStatus)
+				return
+			}
+
+			Expect(r.Method).To(Equal("PUT"))
+			Expect(r.Header.Get("X-Auth-Token")).To(Equal("fake-token"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			if r.URL.Query().Get("multipart-manifest") == "put" {
+				uploadedManifest = string(body)
+			} else {
+				uploadedContents += string(body)
+			}
+
+			uploadedPaths = append(uploadedPaths, r.URL.Path)
+
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		options = ObjectStorageOptions{
+			AuthEndpoint: server.URL + "/auth/v1.0",
+			Username:     "SLOS123-2:SL123",
+			ApiKey:       "fake-api-key",
+			Cluster:      "dal05",
+		}
+	})
+
+	JustBeforeEach(func() {
+		uploader = NewSwiftUploader(options, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Upload", func() {
+		It("creates the container, uploads the image and returns its swift URI", func() {
+			uri, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uri).To(Equal("swift://SLOS123-2@dal05/bosh-stemcells/fake-image.vhd"))
+
+			Expect(uploadedPaths).To(Equal([]string{
+				"/v1/AUTH_fake/bosh-stemcells",
+				"/v1/AUTH_fake/bosh-stemcells/fake-image.vhd",
+			}))
+			Expect(uploadedContents).To(Equal("fake-image-contents"))
+		})
+
+		Context("when the image is larger than the segment size", func() {
+			BeforeEach(func() {
+				options.SegmentSize = 8
+			})
+
+			It("uploads the image in segments and a static large object manifest for them", func() {
+				uri, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(uri).To(Equal("swift://SLOS123-2@dal05/bosh-stemcells/fake-image.vhd"))
+
+				Expect(uploadedPaths).To(Equal([]string{
+					"/v1/AUTH_fake/bosh-stemcells",
+					"/v1/AUTH_fake/bosh-stemcells_segments",
+					"/v1/AUTH_fake/bosh-stemcells_segments/fake-image.vhd/00000000",
+					"/v1/AUTH_fake/bosh-stemcells_segments/fake-image.vhd/00000001",
+					"/v1/AUTH_fake/bosh-stemcells_segments/fake-image.vhd/00000002",
+					"/v1/AUTH_fake/bosh-stemcells/fake-image.vhd",
+				}))
+				Expect(uploadedContents).To(Equal("fake-image-contents"))
+
+				Expect(uploadedManifest).To(MatchJSON(`[
+					{"path": "/bosh-stemcells_segments/fake-image.vhd/00000000", "etag": "` + md5Hex("fake-ima") + `", "size_bytes": 8},
+					{"path": "/bosh-stemcells_segments/fake-image.vhd/00000001", "etag": "` + md5Hex("ge-conte") + `", "size_bytes": 8},
+					{"path": "/bosh-stemcells_segments/fake-image.vhd/00000002", "etag": "` + md5Hex("nts") + `", "size_bytes": 3}
+				]`))
+			})
+
+			Context("when the image is shorter than its size", func() {
+				It("returns error", func() {
+					_, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image"), 19)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Uploading segment '/bosh-stemcells_segments/fake-image.vhd/00000001'"))
+				})
+			})
+		})
+
+		Context("when the segment size is larger than the Swift object limit", func() {
+			BeforeEach(func() {
+				options.SegmentSize = 6 << 30
+			})
+
+			It("returns error", func() {
+				_, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("SegmentSize must be between 0 and 5368709120 bytes"))
+			})
+		})
+
+		Context("when a container is configured", func() {
+			BeforeEach(func() {
+				options.Container = "fake-container"
+			})
+
+			It("uploads the image into that container", func() {
+				uri, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(uri).To(Equal("swift://SLOS123-2@dal05/fake-container/fake-image.vhd"))
+			})
+		})
+
+		Context("when authentication fails", func() {
+			BeforeEach(func() {
+				options.ApiKey = "wrong-api-key"
+			})
+
+			It("returns error", func() {
+				_, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Authenticating with object storage"))
+			})
+		})
+
+		Context("when object storage is not configured", func() {
+			BeforeEach(func() {
+				options = ObjectStorageOptions{}
+			})
+
+			It("returns error", func() {
+				_, err := uploader.Upload("fake-image.vhd", strings.NewReader("fake-image-contents"), 19)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Validating ObjectStorage configuration"))
+			})
+		})
+	})
+})
+
+func md5Hex(contents string) string {
+	sum := md5.Sum([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
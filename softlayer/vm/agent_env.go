@@ -71,24 +71,7 @@ func NewAgentEnvFromJSON(bytes []byte) (AgentEnv, error) {
 }
 
 func NewAgentEnvForVM(agentID, vmCID string, networks Networks, disksSpec DisksSpec, env Environment, agentOptions AgentOptions) AgentEnv {
-	networksSpec := NetworksSpec{}
-
-	for netName, network := range networks {
-		networksSpec[netName] = NetworkSpec{
-			Type: network.Type,
-
-			IP:      network.IP,
-			Netmask: network.Netmask,
-			Gateway: network.Gateway,
-
-			DNS:     network.DNS,
-			Default: network.Default,
-
-			MAC: "",
-
-			CloudProperties: network.CloudProperties,
-		}
-	}
+	networksSpec := newNetworksSpec(networks)
 
 	agentEnv := AgentEnv{
 		AgentID: agentID,
@@ -117,6 +100,12 @@ func NewAgentEnvForVM(agentID, vmCID string, networks Networks, disksSpec DisksS
 	return agentEnv
 }
 
+func (ae AgentEnv) AttachNetworks(networks Networks) AgentEnv {
+	ae.Networks = newNetworksSpec(networks)
+
+	return ae
+}
+
 func (ae AgentEnv) AttachPersistentDisk(diskID, path string) AgentEnv {
 	spec := PersistentSpec{}
 
@@ -148,3 +137,26 @@ func (ae AgentEnv) DetachPersistentDisk(diskID string) AgentEnv {
 
 	return ae
 }
+
+func newNetworksSpec(networks Networks) NetworksSpec {
+	networksSpec := NetworksSpec{}
+
+	for netName, network := range networks {
+		networksSpec[netName] = NetworkSpec{
+			Type: network.Type,
+
+			IP:      network.IP,
+			Netmask: network.Netmask,
+			Gateway: network.Gateway,
+
+			DNS:     network.DNS,
+			Default: network.Default,
+
+			MAC: network.MAC,
+
+			CloudProperties: network.CloudProperties,
+		}
+	}
+
+	return networksSpec
+}
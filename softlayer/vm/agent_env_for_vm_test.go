@@ -20,6 +20,8 @@ var _ = Describe("NewAgentEnvForVM", func() {
 				DNS:     []string{"fake-dns"},
 				Default: []string{"fake-default"},
 
+				MAC: "fake-mac",
+
 				CloudProperties: map[string]interface{}{
 					"fake-cp-key": "fake-cp-value",
 				},
@@ -81,7 +83,7 @@ var _ = Describe("NewAgentEnvForVM", func() {
 					DNS:     []string{"fake-dns"},
 					Default: []string{"fake-default"},
 
-					MAC: "",
+					MAC: "fake-mac",
 
 					CloudProperties: map[string]interface{}{
 						"fake-cp-key": "fake-cp-value",
@@ -1,6 +1,70 @@
 package vm
 
+import (
+	"fmt"
+	"strings"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+)
+
 type NotSupportedError struct{}
 
 func (e NotSupportedError) Type() string  { return "Bosh::Clouds::NotSupported" }
 func (e NotSupportedError) Error() string { return "Not supported" }
+
+type DiskNotAttachedError struct{}
+
+func (e DiskNotAttachedError) Type() string  { return "Bosh::Clouds::DiskNotAttached" }
+func (e DiskNotAttachedError) Error() string { return "Disk not attached" }
+
+// StemcellNotInDatacenterError is returned when the template of a stemcell is
+// not available in the datacenter of the VM and stemcells are not replicated
+type StemcellNotInDatacenterError struct {
+	StemcellId  int
+	Datacenter  string
+	Datacenters []string
+}
+
+func (e StemcellNotInDatacenterError) Type() string { return "Bosh::Clouds::VMCreationFailed" }
+
+func (e StemcellNotInDatacenterError) Error() string {
+	return fmt.Sprintf("Stemcell `%d` is not available in datacenter '%s', only in '%s'; enable ReplicateStemcells to copy it there", e.StemcellId, e.Datacenter, strings.Join(e.Datacenters, "', '"))
+}
+
+// CreationFailedError is returned when a virtual guest was ordered but could
+// not be prepared for the agent. Unless failed VMs are kept for debugging,
+// the virtual guest has been cancelled by the time it is returned.
+type CreationFailedError struct {
+	VirtualGuestId int
+	Cause          error
+
+	Kept         bool
+	CleanupError error
+}
+
+func (e CreationFailedError) Type() string { return "Bosh::Clouds::VMCreationFailed" }
+
+func (e CreationFailedError) Error() string {
+	var outcome string
+	switch {
+	case e.Kept:
+		outcome = "was kept for debugging"
+	case e.CleanupError != nil:
+		outcome = fmt.Sprintf("could not be cancelled (%s)", e.CleanupError.Error())
+	default:
+		outcome = "was cancelled"
+	}
+
+	return fmt.Sprintf("Preparing VirtualGuest `%d`, which %s: %s", e.VirtualGuestId, outcome, e.Cause.Error())
+}
+
+// CanRetry tells whether creating the VM again may succeed. It never does
+// while the virtual guest may still be around, since the director would
+// then order another one next to it.
+func (e CreationFailedError) CanRetry() bool {
+	if e.Kept || e.CleanupError != nil {
+		return false
+	}
+
+	return bslcommon.IsTransientError(e.Cause)
+}
@@ -9,9 +9,25 @@ type FakeFinder struct {
 	FindVM    bslcvm.VM
 	FindFound bool
 	FindErr   error
+
+	FindByPrimaryIPsIPs []string
+	FindByPrimaryIPsVMs map[string]bslcvm.VM
+	FindByPrimaryIPsErr error
 }
 
 func (f *FakeFinder) Find(id int) (bslcvm.VM, bool, error) {
 	f.FindID = id
 	return f.FindVM, f.FindFound, f.FindErr
 }
+
+func (f *FakeFinder) FindByPrimaryIPs(ips []string) (bslcvm.VM, bool, error) {
+	f.FindByPrimaryIPsIPs = ips
+
+	for _, ip := range ips {
+		if vm, found := f.FindByPrimaryIPsVMs[ip]; found {
+			return vm, true, f.FindByPrimaryIPsErr
+		}
+	}
+
+	return nil, false, f.FindByPrimaryIPsErr
+}
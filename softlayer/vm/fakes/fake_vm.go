@@ -28,6 +28,10 @@ type FakeVM struct {
 
 	DetachDiskDisk bslcdisk.Disk
 	DetachDiskErr  error
+
+	GetDisksCalled  bool
+	GetDisksDiskIDs []int
+	GetDisksErr     error
 }
 
 func NewFakeVM(id int) *FakeVM {
@@ -67,3 +71,8 @@ func (vm *FakeVM) DetachDisk(disk bslcdisk.Disk) error {
 	vm.DetachDiskDisk = disk
 	return vm.DetachDiskErr
 }
+
+func (vm *FakeVM) GetDisks() ([]int, error) {
+	vm.GetDisksCalled = true
+	return vm.GetDisksDiskIDs, vm.GetDisksErr
+}
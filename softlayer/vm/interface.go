@@ -16,9 +16,24 @@ type VMCloudProperties struct {
 	SshKeys                  []sldatatypes.SshKey `json:"sshKeys"`
 	RootDiskSize             int                  `json:"rootDiskSize,omitempty"`
 	EphemeralDiskSize        int                  `json:"ephemeralDiskSize,omitempty"`
+
+	// LocalDiskFlag selects local disks instead of SAN disks for the root
+	// and ephemeral block devices. Local disks are used when it is not set,
+	// as SoftLayer does for an order that does not say.
+	LocalDiskFlag *bool `json:"localDiskFlag,omitempty"`
+
+	// Profile names a set of cloud properties from the CPI config to use
+	// for anything left unset
+	Profile string `json:"profile,omitempty"`
+
+	// BareMetal orders a bare-metal server instead of a virtual guest. The
+	// server is ordered with the operating system named by Os, then reloaded
+	// with the stemcell, which has to be an image template.
+	BareMetal *bool  `json:"bare_metal,omitempty"`
+	Os        string `json:"os,omitempty"`
 }
 
-type VMMetadata struct{}
+type VMMetadata map[string]string
 
 type Creator interface {
 	// Create takes an agent id and creates a VM with provided configuration
@@ -27,6 +42,10 @@ type Creator interface {
 
 type Finder interface {
 	Find(int) (VM, bool, error)
+
+	// FindByPrimaryIPs finds the VM whose public or private primary IP is
+	// the first of the given IPs to match any VM
+	FindByPrimaryIPs([]string) (VM, bool, error)
 }
 
 type VM interface {
@@ -40,6 +59,9 @@ type VM interface {
 
 	AttachDisk(bslcdisk.Disk) error
 	DetachDisk(bslcdisk.Disk) error
+
+	// GetDisks returns the IDs of the persistent disks attached to the VM
+	GetDisks() ([]int, error)
 }
 
 type Environment map[string]interface{}
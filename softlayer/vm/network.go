@@ -1,5 +1,11 @@
 package vm
 
+import (
+	"encoding/json"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+)
+
 type Networks map[string]Network
 
 type Network struct {
@@ -12,6 +18,8 @@ type Network struct {
 	DNS     []string
 	Default []string
 
+	MAC string
+
 	CloudProperties map[string]interface{}
 }
 
@@ -24,3 +32,30 @@ func (ns Networks) First() Network {
 }
 
 func (n Network) IsDynamic() bool { return n.Type == "dynamic" }
+
+func (n Network) IsManual() bool { return n.Type == "manual" || n.Type == "" }
+
+type NetworkCloudProperties struct {
+	VlanId   int `json:"vlanId,omitempty"`
+	SubnetId int `json:"subnetId,omitempty"`
+}
+
+func (n Network) SoftLayerCloudProperties() (NetworkCloudProperties, error) {
+	cloudProps := NetworkCloudProperties{}
+
+	if len(n.CloudProperties) == 0 {
+		return cloudProps, nil
+	}
+
+	bytes, err := json.Marshal(n.CloudProperties)
+	if err != nil {
+		return cloudProps, bosherr.WrapError(err, "Marshalling network cloud properties")
+	}
+
+	err = json.Unmarshal(bytes, &cloudProps)
+	if err != nil {
+		return cloudProps, bosherr.WrapError(err, "Unmarshalling network cloud properties")
+	}
+
+	return cloudProps, nil
+}
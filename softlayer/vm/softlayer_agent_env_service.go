@@ -1,11 +1,16 @@
 package vm
 
 import (
-	"errors"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
 	sl "github.com/maximilien/softlayer-go/softlayer"
 
-	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 const (
@@ -19,21 +24,67 @@ const (
 type SoftLayerAgentEnvService struct {
 	vmId            int
 	softLayerClient sl.Client
+	waitOptions     bslcommon.WaitOptions
 	logger          boshlog.Logger
 }
 
-func NewSoftLayerAgentEnvService(vmId int, softLayerClient sl.Client, logger boshlog.Logger) SoftLayerAgentEnvService {
+func NewSoftLayerAgentEnvService(vmId int, softLayerClient sl.Client, waitOptions bslcommon.WaitOptions, logger boshlog.Logger) SoftLayerAgentEnvService {
 	return SoftLayerAgentEnvService{
 		vmId:            vmId,
 		softLayerClient: softLayerClient,
+		waitOptions:     waitOptions,
 		logger:          logger,
 	}
 }
 
 func (s SoftLayerAgentEnvService) Fetch() (AgentEnv, error) {
-	return AgentEnv{}, errors.New("Implement me!")
+	virtualGuestService, err := s.softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+	if err != nil {
+		return AgentEnv{}, bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
+	}
+
+	userData, err := virtualGuestService.GetUserData(s.vmId)
+	if err != nil {
+		return AgentEnv{}, bosherr.WrapError(err, fmt.Sprintf("Getting user data from VirtualGuest `%d`", s.vmId))
+	}
+
+	if len(userData) == 0 {
+		return AgentEnv{}, bosherr.Errorf("Expected to find user data on VirtualGuest `%d`", s.vmId)
+	}
+
+	// softlayer-go base64 encodes the metadata when setting it
+	contents, err := base64.StdEncoding.DecodeString(userData[0].Value)
+	if err != nil {
+		contents = []byte(userData[0].Value)
+	}
+
+	s.logger.DebugWithDetails(softLayerAgentEnvServiceLogTag, "Fetched agent env", string(contents))
+
+	agentEnv, err := NewAgentEnvFromJSON(contents)
+	if err != nil {
+		return AgentEnv{}, bosherr.WrapError(err, "Unmarshalling agent env")
+	}
+
+	return agentEnv, nil
 }
 
 func (s SoftLayerAgentEnvService) Update(agentEnv AgentEnv) error {
-	return errors.New("Implement me!")
+	contents, err := json.Marshal(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling agent env")
+	}
+
+	s.logger.DebugWithDetails(softLayerAgentEnvServiceLogTag, "Updating agent env", string(contents))
+
+	err = bslcommon.ConfigureMetadataOnVirtualGuest(s.softLayerClient, s.vmId, string(contents), s.waitOptions)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", s.vmId))
+	}
+
+	err = bslcommon.WaitForVirtualGuestToHaveNoRunningTransactions(s.softLayerClient, s.vmId, s.waitOptions)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", s.vmId))
+	}
+
+	return nil
 }
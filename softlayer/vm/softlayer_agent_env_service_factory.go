@@ -4,20 +4,24 @@ import (
 	sl "github.com/maximilien/softlayer-go/softlayer"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 type SoftLayerAgentEnvServiceFactory struct {
-	client sl.Client
-	logger boshlog.Logger
+	client      sl.Client
+	waitOptions bslcommon.WaitOptions
+	logger      boshlog.Logger
 }
 
-func NewSoftLayerAgentEnvServiceFactory(client sl.Client, logger boshlog.Logger) SoftLayerAgentEnvServiceFactory {
+func NewSoftLayerAgentEnvServiceFactory(client sl.Client, waitOptions bslcommon.WaitOptions, logger boshlog.Logger) SoftLayerAgentEnvServiceFactory {
 	return SoftLayerAgentEnvServiceFactory{
-		client: client,
-		logger: logger,
+		client:      client,
+		waitOptions: waitOptions,
+		logger:      logger,
 	}
 }
 
 func (f SoftLayerAgentEnvServiceFactory) New(vmId int) AgentEnvService {
-	return NewSoftLayerAgentEnvService(vmId, f.client, f.logger)
+	return NewSoftLayerAgentEnvService(vmId, f.client, f.waitOptions, f.logger)
 }
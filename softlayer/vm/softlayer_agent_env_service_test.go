@@ -1,11 +1,17 @@
 package vm_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
-	//. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega"
 
 	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
 )
@@ -22,18 +28,62 @@ var _ = Describe("SoftLayerAgentEnvService", func() {
 		vmId = 1234567
 		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
 		logger = boshlog.NewLogger(boshlog.LevelNone)
-		agentEnvService = NewSoftLayerAgentEnvService(vmId, softLayerClient, logger)
+		waitOptions := bslcommon.WaitOptions{Timeout: 1 * time.Second, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+		agentEnvService = NewSoftLayerAgentEnvService(vmId, softLayerClient, waitOptions, logger)
 	})
 
 	Context("#Fetch", func() {
 		It("Returns an AgentEnv object built with current metadata when fetched", func() {
-			//Implement me!
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getUserData.json")
+
+			agentEnv, err := agentEnvService.Fetch()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(agentEnv.AgentID).To(Equal("fake-agent-id"))
+			Expect(agentEnv.VM).To(Equal(VMSpec{Name: "1234567", ID: "1234567"}))
+			Expect(agentEnv.Mbus).To(Equal("fake-mbus"))
+			Expect(agentEnv.Disks.Ephemeral).To(Equal("/dev/xvdc"))
+			Expect(agentEnv.Disks.Persistent).To(Equal(PersistentSpec{"1234": "/dev/sdb"}))
+		})
+
+		It("Returns an error when the VM has no user data", func() {
+			common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getEmptyUserData.json")
+
+			_, err := agentEnvService.Fetch()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected to find user data"))
 		})
 	})
 
 	Context("#Update", func() {
 		It("Sets the VM's metadata using the AgentEnv object passed", func() {
-			//Implement me!
+			fileNames := []string{
+				"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+				"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+
+				"SoftLayer_Virtual_Guest_Service_setMetadata.json",
+				"SoftLayer_Virtual_Guest_Service_configureMetadataDisk.json",
+
+				"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+				"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			err := agentEnvService.Update(AgentEnv{AgentID: "fake-agent-id"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Returns an error when the VM's metadata cannot be set", func() {
+			fileNames := []string{
+				"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+				"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+
+				"SoftLayer_Virtual_Guest_Service_setMetadata_false.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			err := agentEnvService.Update(AgentEnv{AgentID: "fake-agent-id"})
+			Expect(err).To(HaveOccurred())
 		})
 	})
 })
@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+)
+
+const (
+	// Device 1 is reserved by SoftLayer for swap
+	rootBlockDevice      = "0"
+	ephemeralBlockDevice = "2"
+
+	ephemeralDiskPath = "/dev/xvdc"
+)
+
+func blockDevicesFor(cloudProps VMCloudProperties) []sldatatypes.BlockDevice {
+	blockDevices := []sldatatypes.BlockDevice{}
+
+	if cloudProps.RootDiskSize > 0 {
+		blockDevices = append(blockDevices, sldatatypes.BlockDevice{
+			Device:    rootBlockDevice,
+			DiskImage: sldatatypes.DiskImage{Capacity: cloudProps.RootDiskSize},
+		})
+	}
+
+	if cloudProps.EphemeralDiskSize > 0 {
+		blockDevices = append(blockDevices, sldatatypes.BlockDevice{
+			Device:    ephemeralBlockDevice,
+			DiskImage: sldatatypes.DiskImage{Capacity: cloudProps.EphemeralDiskSize},
+		})
+	}
+
+	return blockDevices
+}
+
+// blockDeviceProblems describes every block device whose size SoftLayer does
+// not offer for the requested kind (local or SAN) of disk
+func blockDeviceProblems(blockDevices []sldatatypes.BlockDevice, localDiskFlag bool, options createObjectOptions) []string {
+	offered := map[string][]int{}
+	for _, option := range options.BlockDevices {
+		if option.Template.LocalDiskFlag != localDiskFlag {
+			continue
+		}
+
+		for _, blockDevice := range option.Template.BlockDevices {
+			offered[blockDevice.Device] = append(offered[blockDevice.Device], blockDevice.DiskImage.Capacity)
+		}
+	}
+
+	diskKind := "SAN"
+	if localDiskFlag {
+		diskKind = "local"
+	}
+
+	problems := []string{}
+	for _, blockDevice := range blockDevices {
+		capacities := offered[blockDevice.Device]
+		if containsInt(capacities, blockDevice.DiskImage.Capacity) {
+			continue
+		}
+
+		sort.Ints(capacities)
+		problems = append(problems, fmt.Sprintf("%s size %dGB is not offered for %s disks, available sizes are %v", blockDeviceName(blockDevice.Device), blockDevice.DiskImage.Capacity, diskKind, capacities))
+	}
+
+	return problems
+}
+
+func blockDeviceName(device string) string {
+	switch device {
+	case rootBlockDevice:
+		return "RootDiskSize"
+	case ephemeralBlockDevice:
+		return "EphemeralDiskSize"
+	default:
+		return fmt.Sprintf("Block device %s", device)
+	}
+}
@@ -1,10 +1,10 @@
 package vm
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
-	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
@@ -12,6 +12,8 @@ import (
 	sldatatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
 
+	bslcapi "github.com/maximilien/bosh-softlayer-cpi/api"
+	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
 	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 	bslcstem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell"
 )
@@ -23,96 +25,356 @@ type SoftLayerCreator struct {
 	agentEnvServiceFactory AgentEnvServiceFactory
 
 	agentOptions AgentOptions
-	logger       boshlog.Logger
-}
+	waitOptions  bslcommon.OperationWaitOptions
+
+	// Leaves virtual guests that fail to be prepared running instead of
+	// cancelling them
+	keepFailedVMs bool
 
-func NewSoftLayerCreator(softLayerClient sl.Client, agentEnvServiceFactory AgentEnvServiceFactory, agentOptions AgentOptions, logger boshlog.Logger) SoftLayerCreator {
-	bslcommon.TIMEOUT = 20 * time.Minute
-	bslcommon.POLLING_INTERVAL = 20 * time.Second
-	bslcommon.MAX_RETRY_COUNT = 5
+	// Copies stemcell templates to the datacenter of the VM when they are
+	// not available there instead of failing
+	replicateStemcells bool
+
+	logger boshlog.Logger
+}
 
+func NewSoftLayerCreator(softLayerClient sl.Client, agentEnvServiceFactory AgentEnvServiceFactory, agentOptions AgentOptions, waitOptions bslcommon.OperationWaitOptions, keepFailedVMs bool, replicateStemcells bool, logger boshlog.Logger) SoftLayerCreator {
 	return SoftLayerCreator{
 		softLayerClient:        softLayerClient,
 		agentEnvServiceFactory: agentEnvServiceFactory,
 		agentOptions:           agentOptions,
+		waitOptions:            waitOptions,
+		keepFailedVMs:          keepFailedVMs,
+		replicateStemcells:     replicateStemcells,
 		logger:                 logger,
 	}
 }
 
 func (c SoftLayerCreator) Create(agentID string, stemcell bslcstem.Stemcell, cloudProps VMCloudProperties, networks Networks, env Environment) (VM, error) {
-	virtualGuestTemplate := sldatatypes.SoftLayer_Virtual_Guest_Template{
-		Hostname:  agentID,
-		Domain:    cloudProps.Domain,
-		StartCpus: cloudProps.StartCpus,
-		MaxMemory: cloudProps.MaxMemory,
-
-		Datacenter: sldatatypes.Datacenter{
-			Name: cloudProps.Datacenter.Name,
-		},
+	if cloudProps.UsesBareMetal() {
+		return c.createBareMetal(agentID, stemcell, cloudProps, networks, env)
+	}
+
+	err := cloudProps.Validate(c.softLayerClient)
+	if err != nil {
+		if _, ok := err.(bslcommon.InvalidCloudPropertiesError); ok {
+			return SoftLayerVM{}, err
+		}
+
+		return SoftLayerVM{}, bosherr.WrapError(err, "Validating VM cloud properties")
+	}
+
+	if stemcell.Kind() == bslcstem.VirtualGuestDeviceTemplateGroupKind {
+		err = c.ensureStemcellInDatacenter(stemcell.ID(), cloudProps.Datacenter.Name)
+		if err != nil {
+			if _, ok := err.(StemcellNotInDatacenterError); ok {
+				return SoftLayerVM{}, err
+			}
+
+			return SoftLayerVM{}, bosherr.WrapErrorf(err, "Making stemcell `%d` available in datacenter '%s'", stemcell.ID(), cloudProps.Datacenter.Name)
+		}
+	}
+
+	virtualGuestTemplate := virtualGuestTemplate{
+		SoftLayer_Virtual_Guest_Template: sldatatypes.SoftLayer_Virtual_Guest_Template{
+			Hostname:  agentID,
+			Domain:    cloudProps.Domain,
+			StartCpus: cloudProps.StartCpus,
+			MaxMemory: cloudProps.MaxMemory,
+
+			Datacenter: sldatatypes.Datacenter{
+				Name: cloudProps.Datacenter.Name,
+			},
+
+			BlockDeviceTemplateGroup: &sldatatypes.BlockDeviceTemplateGroup{
+				GlobalIdentifier: stemcell.Uuid(),
+			},
 
-		BlockDeviceTemplateGroup: &sldatatypes.BlockDeviceTemplateGroup{
-			GlobalIdentifier: stemcell.Uuid(),
+			SshKeys:           cloudProps.SshKeys,
+			HourlyBillingFlag: true,
+
+			LocalDiskFlag: cloudProps.UsesLocalDisks(),
+			BlockDevices:  blockDevicesFor(cloudProps),
 		},
+	}
 
-		SshKeys:           cloudProps.SshKeys,
-		HourlyBillingFlag: true,
+	networkSpaces, err := c.assignNetworkSpaces(networks, &virtualGuestTemplate)
+	if err != nil {
+		return SoftLayerVM{}, bosherr.WrapError(err, "Assigning networks")
+	}
 
-		// Needed for ephemeral disk
-		LocalDiskFlag: true,
+	virtualGuest, err := c.createVirtualGuest(virtualGuestTemplate)
+	if err != nil {
+		return SoftLayerVM{}, bslcapi.WrapError(err, "Creating VirtualGuest from SoftLayer client")
 	}
 
-	virtualGuestService, err := c.softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+	err = c.prepareVirtualGuest(virtualGuest.Id, agentID, cloudProps, networks, networkSpaces, env)
 	if err != nil {
-		return SoftLayerVM{}, bosherr.WrapError(err, "Creating VirtualGuestService from SoftLayer client")
+		return SoftLayerVM{}, c.cleanUpFailedVirtualGuest(virtualGuest.Id, err)
 	}
 
-	virtualGuest, err := virtualGuestService.CreateObject(virtualGuestTemplate)
+	agentEnvService := c.agentEnvServiceFactory.New(virtualGuest.Id)
+
+	vm := NewSoftLayerVM(virtualGuest.Id, c.softLayerClient, agentEnvService, c.waitOptions, c.logger)
+
+	return vm, nil
+}
+
+// ensureStemcellInDatacenter replicates the template group of a stemcell to
+// the datacenter when it is not available there yet, and waits for the copy
+// to be listed in the datacenter and active
+func (c SoftLayerCreator) ensureStemcellInDatacenter(templateGroupId int, datacenterName string) error {
+	templateGroupService, err := c.softLayerClient.GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
 	if err != nil {
-		return SoftLayerVM{}, bosherr.WrapError(err, "Creating VirtualGuest from SoftLayer client")
+		return bosherr.WrapError(err, "Creating VirtualGuestBlockDeviceTemplateGroupService from SoftLayer client")
 	}
 
-	//TODO: need to find or ensure the name for the ephemeral disk for SoftLayer VG
-	disks := DisksSpec{Ephemeral: "/dev/xvdc"}
+	locations, err := templateGroupService.GetDatacenters(templateGroupId)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting datacenters of VirtualGuestBlockDeviceTemplateGroup `%d`", templateGroupId)
+	}
+
+	datacenterNames := []string{}
+	for _, location := range locations {
+		if location.Name == datacenterName {
+			return nil
+		}
 
-	agentEnv := NewAgentEnvForVM(agentID, strconv.Itoa(virtualGuest.Id), networks, disks, env, c.agentOptions)
+		datacenterNames = append(datacenterNames, location.Name)
+	}
+
+	if !c.replicateStemcells {
+		return StemcellNotInDatacenterError{StemcellId: templateGroupId, Datacenter: datacenterName, Datacenters: datacenterNames}
+	}
+
+	datacenter, err := c.findDatacenter(datacenterName)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info(softLayerCreatorLogTag, "Replicating VirtualGuestBlockDeviceTemplateGroup `%d` to datacenter '%s'", templateGroupId, datacenterName)
+
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"parameters": []interface{}{[]sldatatypes.SoftLayer_Location{datacenter}},
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling locations")
+	}
+
+	response, err := c.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest_Block_Device_Template_Group/%d/addLocations.json", templateGroupId), "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Adding datacenter '%s' to VirtualGuestBlockDeviceTemplateGroup `%d`", datacenterName, templateGroupId)
+	}
+
+	if res := string(response); res != "true" {
+		return bosherr.Errorf("Failed to add datacenter '%s' to VirtualGuestBlockDeviceTemplateGroup `%d`, got '%s' as response from the API", datacenterName, templateGroupId, res)
+	}
+
+	err = bslcommon.WaitForVirtualGuestBlockDeviceTemplateGroupInDatacenter(c.softLayerClient, templateGroupId, datacenterName, c.waitOptions.Create)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Waiting for VirtualGuestBlockDeviceTemplateGroup `%d` to be replicated to datacenter '%s'", templateGroupId, datacenterName)
+	}
+
+	err = bslcommon.WaitForVirtualGuestBlockDeviceTemplateGroup(c.softLayerClient, templateGroupId, "ACTIVE", c.waitOptions.Create)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Waiting for VirtualGuestBlockDeviceTemplateGroup `%d` to be active", templateGroupId)
+	}
+
+	return nil
+}
+
+func (c SoftLayerCreator) findDatacenter(name string) (sldatatypes.SoftLayer_Location, error) {
+	response, err := c.softLayerClient.DoRawHttpRequest("SoftLayer_Location_Datacenter/getDatacenters.json", "GET", new(bytes.Buffer))
+	if err != nil {
+		return sldatatypes.SoftLayer_Location{}, bosherr.WrapError(err, "Getting datacenters from SoftLayer client")
+	}
+
+	datacenters := []sldatatypes.SoftLayer_Location{}
+	err = json.Unmarshal(response, &datacenters)
+	if err != nil {
+		return sldatatypes.SoftLayer_Location{}, bosherr.WrapError(err, "Unmarshalling datacenters")
+	}
+
+	for _, datacenter := range datacenters {
+		if datacenter.Name == name {
+			return datacenter, nil
+		}
+	}
+
+	return sldatatypes.SoftLayer_Location{}, bosherr.Errorf("Datacenter '%s' does not exist", name)
+}
+
+// prepareVirtualGuest resolves the networks of an ordered virtual guest and
+// hands the agent environment to it through user metadata
+func (c SoftLayerCreator) prepareVirtualGuest(virtualGuestId int, agentID string, cloudProps VMCloudProperties, networks Networks, networkSpaces map[string]string, env Environment) error {
+	if len(networkSpaces) > 0 {
+		err := bslcommon.WaitForVirtualGuest(c.softLayerClient, virtualGuestId, "RUNNING", c.waitOptions.Create)
+		if err != nil {
+			return bslcapi.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d`", virtualGuestId))
+		}
+
+		components, err := getNetworkComponents(c.softLayerClient, virtualGuestId)
+		if err != nil {
+			return bslcapi.WrapError(err, fmt.Sprintf("Getting network components of VirtualGuest `%d`", virtualGuestId))
+		}
+
+		networks, err = resolveNetworks(virtualGuestId, networks, networkSpaces, components)
+		if err != nil {
+			return bosherr.WrapError(err, fmt.Sprintf("Resolving networks of VirtualGuest `%d`", virtualGuestId))
+		}
+	}
+
+	disks := DisksSpec{}
+	if cloudProps.EphemeralDiskSize > 0 {
+		disks.Ephemeral = ephemeralDiskPath
+	}
+
+	agentEnv := NewAgentEnvForVM(agentID, strconv.Itoa(virtualGuestId), networks, disks, env, c.agentOptions)
 
 	metadata, err := json.Marshal(agentEnv)
 	if err != nil {
-		return SoftLayerVM{}, bosherr.WrapError(err, "Marshalling agent environment metadata")
+		return bosherr.WrapError(err, "Marshalling agent environment metadata")
+	}
+
+	err = bslcommon.ConfigureMetadataOnVirtualGuest(c.softLayerClient, virtualGuestId, string(metadata), c.waitOptions.Metadata)
+	if err != nil {
+		return bslcapi.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", virtualGuestId))
+	}
+
+	return nil
+}
+
+// cleanUpFailedVirtualGuest cancels a virtual guest that could not be
+// prepared so it is not left running and billed without the director ever
+// learning its ID
+func (c SoftLayerCreator) cleanUpFailedVirtualGuest(virtualGuestId int, cause error) error {
+	creationErr := CreationFailedError{VirtualGuestId: virtualGuestId, Cause: cause}
+
+	if c.keepFailedVMs {
+		c.logger.Info(softLayerCreatorLogTag, "Keeping VirtualGuest `%d` that failed to be prepared for debugging", virtualGuestId)
+		creationErr.Kept = true
+		return creationErr
+	}
+
+	c.logger.Debug(softLayerCreatorLogTag, "Cancelling VirtualGuest `%d` that failed to be prepared", virtualGuestId)
+
+	vm := NewSoftLayerVM(virtualGuestId, c.softLayerClient, c.agentEnvServiceFactory.New(virtualGuestId), c.waitOptions, c.logger)
+
+	err := vm.Delete()
+	if err != nil {
+		c.logger.Error(softLayerCreatorLogTag, "Cancelling VirtualGuest `%d` that failed to be prepared: %s", virtualGuestId, err.Error())
+		creationErr.CleanupError = err
 	}
 
-	err = bslcommon.ConfigureMetadataOnVirtualGuest(c.softLayerClient, virtualGuest.Id, string(metadata), bslcommon.TIMEOUT, bslcommon.POLLING_INTERVAL)
+	return creationErr
+}
+
+// createBareMetal orders a bare-metal server, waits for it to be provisioned,
+// hands it the agent environment and reloads it with the stemcell. Like
+// virtual guests, servers that fail after being ordered are cancelled unless
+// failed VMs are kept.
+func (c SoftLayerCreator) createBareMetal(agentID string, stemcell bslcstem.Stemcell, cloudProps VMCloudProperties, networks Networks, env Environment) (VM, error) {
+	err := cloudProps.validateBareMetal()
 	if err != nil {
-		return SoftLayerVM{}, bosherr.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", virtualGuest.Id))
+		return SoftLayerHardwareVM{}, err
+	}
+
+	// Only image templates can be loaded onto hardware, so fail before
+	// ordering a server that could not run the stemcell
+	if stemcell.Kind() != bslcstem.VirtualGuestDeviceTemplateGroupKind {
+		return SoftLayerHardwareVM{}, bosherr.Errorf("Bare-metal servers can only be provisioned from image template stemcells, stemcell `%d` is a %s", stemcell.ID(), stemcell.Kind())
 	}
 
-	err = bslcommon.AttachEphemeralDiskToVirtualGuest(c.softLayerClient, virtualGuest.Id, cloudProps.EphemeralDiskSize, bslcommon.TIMEOUT, bslcommon.POLLING_INTERVAL)
+	bmCreator := bslcbm.NewBaremetalCreator(c.softLayerClient, c.logger)
+	bmFinder := bslcbm.NewBaremetalFinder(c.softLayerClient, c.logger)
+
+	hardware, err := bmCreator.Create(cloudProps.BareMetalCloudProperties(agentID))
 	if err != nil {
-		return SoftLayerVM{}, bosherr.WrapError(err, fmt.Sprintf("Attaching ephemeral disk to VirtualGuest `%d`", virtualGuest.Id))
+		if _, ok := err.(bslcommon.InvalidCloudPropertiesError); ok {
+			return SoftLayerHardwareVM{}, err
+		}
+
+		return SoftLayerHardwareVM{}, bosherr.WrapError(err, "Creating Hardware from SoftLayer client")
 	}
 
-	agentEnvService := c.agentEnvServiceFactory.New(virtualGuest.Id)
+	vm, err := c.prepareBareMetal(bmFinder, hardware.GlobalIdentifier, stemcell, agentID, networks, env)
+	if err != nil {
+		if c.keepFailedVMs {
+			c.logger.Info(softLayerCreatorLogTag, "Keeping Hardware '%s' that failed to be prepared for debugging", hardware.GlobalIdentifier)
+			return SoftLayerHardwareVM{}, bosherr.WrapErrorf(err, "Preparing Hardware '%s', which was kept for debugging", hardware.GlobalIdentifier)
+		}
 
-	vm := NewSoftLayerVM(virtualGuest.Id, c.softLayerClient, agentEnvService, c.logger)
+		cancelErr := bmCreator.Cancel(hardware.GlobalIdentifier, c.waitOptions.BareMetal)
+		if cancelErr != nil {
+			c.logger.Error(softLayerCreatorLogTag, "Cancelling Hardware '%s' that failed to be prepared: %s", hardware.GlobalIdentifier, cancelErr.Error())
+			return SoftLayerHardwareVM{}, bosherr.WrapErrorf(err, "Preparing Hardware '%s', which could not be cancelled (%s)", hardware.GlobalIdentifier, cancelErr.Error())
+		}
+
+		return SoftLayerHardwareVM{}, bosherr.WrapErrorf(err, "Preparing Hardware '%s', which was cancelled", hardware.GlobalIdentifier)
+	}
+
+	return vm, nil
+}
+
+// prepareBareMetal sets the agent environment before reloading the server
+// since the user metadata it is handed through is kept across the reload
+func (c SoftLayerCreator) prepareBareMetal(bmFinder bslcbm.BaremetalFinder, globalIdentifier string, stemcell bslcstem.Stemcell, agentID string, networks Networks, env Environment) (SoftLayerHardwareVM, error) {
+	provisioned, err := bmFinder.WaitForProvisioning(globalIdentifier, c.waitOptions.BareMetal)
+	if err != nil {
+		return SoftLayerHardwareVM{}, bosherr.WrapError(err, "Waiting for Hardware to be provisioned")
+	}
+
+	if !provisioned {
+		return SoftLayerHardwareVM{}, bosherr.Errorf("Hardware was not provisioned within %s", c.waitOptions.BareMetal.Timeout)
+	}
+
+	server, err := bmFinder.Describe(globalIdentifier)
+	if err != nil {
+		return SoftLayerHardwareVM{}, bosherr.WrapError(err, "Describing provisioned Hardware")
+	}
+
+	vm := NewSoftLayerHardwareVM(server.Id, c.softLayerClient, c.waitOptions, c.logger)
+
+	agentEnv := NewAgentEnvForVM(agentID, strconv.Itoa(vm.ID()), networks, DisksSpec{}, env, c.agentOptions)
+
+	err = vm.setAgentEnv(agentEnv)
+	if err != nil {
+		return SoftLayerHardwareVM{}, err
+	}
+
+	err = vm.reloadOperatingSystem(stemcell.ID())
+	if err != nil {
+		return SoftLayerHardwareVM{}, err
+	}
 
 	return vm, nil
 }
 
-func (c SoftLayerCreator) resolveNetworkIP(networks Networks) (string, error) {
-	var network Network
+func (c SoftLayerCreator) createVirtualGuest(template virtualGuestTemplate) (sldatatypes.SoftLayer_Virtual_Guest, error) {
+	parameters := map[string]interface{}{
+		"parameters": []virtualGuestTemplate{template},
+	}
 
-	switch len(networks) {
-	case 0:
-		return "", bosherr.Error("Expected exactly one network; received zero")
-	case 1:
-		network = networks.First()
-	default:
-		return "", bosherr.Error("Expected exactly one network; received multiple")
+	requestBody, err := json.Marshal(parameters)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest{}, bosherr.WrapError(err, "Marshalling VirtualGuest template")
 	}
 
-	if network.IsDynamic() {
-		return "", nil
+	response, err := c.softLayerClient.DoRawHttpRequest("SoftLayer_Virtual_Guest.json", "POST", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest{}, err
+	}
+
+	err = c.softLayerClient.CheckForHttpResponseErrors(response)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest{}, err
+	}
+
+	virtualGuest := sldatatypes.SoftLayer_Virtual_Guest{}
+	err = json.Unmarshal(response, &virtualGuest)
+	if err != nil {
+		return sldatatypes.SoftLayer_Virtual_Guest{}, bosherr.WrapError(err, "Unmarshalling VirtualGuest")
 	}
 
-	return network.IP, nil
+	return virtualGuest, nil
 }
@@ -1,12 +1,20 @@
 package vm_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
 	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
 
 	fakestem "github.com/maximilien/bosh-softlayer-cpi/softlayer/stemcell/fakes"
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
@@ -24,6 +32,7 @@ var _ = Describe("SoftLayerCreator", func() {
 		softLayerClient        *fakeslclient.FakeSoftLayerClient
 		agentEnvServiceFactory *fakevm.FakeAgentEnvServiceFactory
 		agentOptions           AgentOptions
+		waitOptions            bslcommon.OperationWaitOptions
 		logger                 boshlog.Logger
 		creator                SoftLayerCreator
 	)
@@ -33,12 +42,24 @@ var _ = Describe("SoftLayerCreator", func() {
 
 		agentEnvServiceFactory = &fakevm.FakeAgentEnvServiceFactory{}
 		agentOptions = AgentOptions{Mbus: "fake-mbus"}
+
+		fastWaitOptions := bslcommon.WaitOptions{Timeout: 1 * time.Second, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+		waitOptions = bslcommon.OperationWaitOptions{
+			Create:    fastWaitOptions,
+			Metadata:  fastWaitOptions,
+			Attach:    fastWaitOptions,
+			Delete:    fastWaitOptions,
+			BareMetal: fastWaitOptions,
+		}
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
 		creator = NewSoftLayerCreator(
 			softLayerClient,
 			agentEnvServiceFactory,
 			agentOptions,
+			waitOptions,
+			false,
+			false,
 			logger,
 		)
 	})
@@ -65,11 +86,12 @@ var _ = Describe("SoftLayerCreator", func() {
 					},
 					RootDiskSize:      25,
 					EphemeralDiskSize: 25,
-					Datacenter:        sldatatypes.Datacenter{Name: "fake-datacenter"},
+					Datacenter:        sldatatypes.Datacenter{Name: "ams01"},
 				}
 				networks = Networks{}
 				env = Environment{}
 
+				setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
 				setFakeSoftLayerClientCreateObjectTestFixtures(softLayerClient)
 			})
 
@@ -78,6 +100,515 @@ var _ = Describe("SoftLayerCreator", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(vm.ID()).To(Equal(1234567))
 			})
+
+			Context("when networks select VLANs and subnets", func() {
+				BeforeEach(func() {
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
+
+					networks = Networks{
+						"fake-private-net": Network{
+							Type:            "manual",
+							IP:              "10.106.192.42",
+							CloudProperties: map[string]interface{}{"vlanId": 524954, "subnetId": 1234},
+						},
+						"fake-public-net": Network{
+							Type:            "dynamic",
+							CloudProperties: map[string]interface{}{"vlanId": 524956},
+						},
+					}
+
+					fileNames := []string{
+						"SoftLayer_Network_Vlan_Service_getObject_Private.json",
+						"SoftLayer_Network_Vlan_Service_getObject_Public.json",
+					}
+					common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+					setFakeSoftLayerClientCreateObjectWithNetworksTestFixtures(softLayerClient)
+				})
+
+				It("returns a new SoftLayerVM", func() {
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.ID()).To(Equal(1234567))
+				})
+
+				It("orders the VLANs and subnet and hands the assigned addresses to the agent", func() {
+					recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+					creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					template := sentVirtualGuestTemplate(recordingClient)
+					Expect(template["primaryBackendNetworkComponent"]).To(Equal(map[string]interface{}{
+						"networkVlan": map[string]interface{}{"id": 524954.0, "primarySubnet": map[string]interface{}{"id": 1234.0}},
+					}))
+					Expect(template["primaryNetworkComponent"]).To(Equal(map[string]interface{}{
+						"networkVlan": map[string]interface{}{"id": 524956.0},
+					}))
+
+					agentEnv := sentAgentEnv(recordingClient, 1234567)
+					Expect(agentEnv.Networks["fake-private-net"]).To(Equal(NetworkSpec{
+						Type:    "manual",
+						IP:      "10.106.192.42",
+						Netmask: "255.255.255.192",
+						Gateway: "10.106.192.1",
+						MAC:     "06:00:00:00:00:02",
+
+						CloudProperties: map[string]interface{}{"vlanId": 524954.0, "subnetId": 1234.0},
+					}))
+					Expect(agentEnv.Networks["fake-public-net"]).To(Equal(NetworkSpec{
+						Type:    "dynamic",
+						IP:      "23.246.234.32",
+						Netmask: "255.255.255.240",
+						Gateway: "23.246.234.33",
+						MAC:     "06:00:00:00:00:01",
+
+						CloudProperties: map[string]interface{}{"vlanId": 524956.0},
+					}))
+				})
+				It("fails and cancels the VM when SoftLayer assigned another IP than the manual network's", func() {
+					network := networks["fake-private-net"]
+					network.IP = "10.106.192.99"
+					networks["fake-private-net"] = network
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Manual network 'fake-private-net' of VirtualGuest `1234567` requires IP '10.106.192.99' but SoftLayer assigned '10.106.192.42'"))
+					Expect(err).To(BeAssignableToTypeOf(CreationFailedError{}))
+					Expect(err.(CreationFailedError).Kept).To(BeFalse())
+				})
+			})
+
+			Context("when a dynamic network does not select a VLAN", func() {
+				BeforeEach(func() {
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
+
+					networks = Networks{
+						"fake-net": Network{Type: "dynamic"},
+					}
+
+					setFakeSoftLayerClientCreateObjectWithNetworksTestFixtures(softLayerClient)
+				})
+
+				It("returns a new SoftLayerVM using the IP assigned by SoftLayer", func() {
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.ID()).To(Equal(1234567))
+				})
+
+				It("leaves the VLAN to SoftLayer and hands the private address to the agent", func() {
+					recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+					creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					template := sentVirtualGuestTemplate(recordingClient)
+					Expect(template).ToNot(HaveKey("primaryBackendNetworkComponent"))
+					Expect(template).ToNot(HaveKey("primaryNetworkComponent"))
+
+					agentEnv := sentAgentEnv(recordingClient, 1234567)
+					Expect(agentEnv.Networks["fake-net"]).To(Equal(NetworkSpec{
+						Type:    "dynamic",
+						IP:      "10.106.192.42",
+						Netmask: "255.255.255.192",
+						Gateway: "10.106.192.1",
+						MAC:     "06:00:00:00:00:02",
+					}))
+				})
+			})
+
+			It("orders local disks when localDiskFlag is not set", func() {
+				recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+				creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+				_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(sentVirtualGuestTemplate(recordingClient)["localDiskFlag"]).To(BeTrue())
+			})
+
+			Context("when SAN disks are requested", func() {
+				BeforeEach(func() {
+					localDisks := false
+					cloudProps.LocalDiskFlag = &localDisks
+					cloudProps.EphemeralDiskSize = 10
+				})
+
+				It("returns a new SoftLayerVM", func() {
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.ID()).To(Equal(1234567))
+				})
+
+				It("orders SAN disks", func() {
+					recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+					creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(sentVirtualGuestTemplate(recordingClient)["localDiskFlag"]).To(BeFalse())
+				})
+			})
+
+			Context("when the virtual guest fails to be prepared after being ordered", func() {
+				BeforeEach(func() {
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
+
+					fileNames := []string{
+						"SoftLayer_Virtual_Guest_Service_createObject.json",
+						"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+						"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+						"SoftLayer_Virtual_Guest_Service_setMetadata_false.json",
+					}
+					common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+				})
+
+				It("cancels the virtual guest and returns a CreationFailedError", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte("[]"), []byte("true"))
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(CreationFailedError{}))
+					Expect(err.Error()).To(ContainSubstring("Preparing VirtualGuest `1234567`, which was cancelled"))
+					Expect(err.(CreationFailedError).CanRetry()).To(BeFalse())
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+				})
+
+				It("reports a transient cause as one that can be retried", func() {
+					// Fail the first power state request instead of setting the metadata
+					responses := softLayerClient.DoRawHttpRequestResponses
+					responses = append(responses[:len(responses)-3], []byte(`{"error": "Please try again later.", "code": "SoftLayer_Exception_Public"}`), []byte("[]"), []byte("true"))
+					softLayerClient.DoRawHttpRequestResponses = responses
+
+					retryingClient := bslcommon.NewRetryingClient(softLayerClient, bslcommon.RetryOptions{MaxAttempts: 1, Sleeper: util.NewRecordingNoopSleeper()}, logger)
+					creator = NewSoftLayerCreator(retryingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(CreationFailedError{}))
+					Expect(err.(CreationFailedError).CanRetry()).To(BeTrue())
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+				})
+
+				It("reports when the virtual guest could not be cancelled", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte("[]"), []byte("false"))
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(CreationFailedError{}))
+					Expect(err.(CreationFailedError).CleanupError).To(HaveOccurred())
+					Expect(err.(CreationFailedError).CanRetry()).To(BeFalse())
+					Expect(err.Error()).To(ContainSubstring("could not be cancelled"))
+				})
+
+				It("keeps the virtual guest when failed VMs are kept for debugging", func() {
+					creator = NewSoftLayerCreator(softLayerClient, agentEnvServiceFactory, agentOptions, waitOptions, true, false, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(CreationFailedError{}))
+					Expect(err.(CreationFailedError).Kept).To(BeTrue())
+					Expect(err.Error()).To(ContainSubstring("which was kept for debugging"))
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+				})
+			})
+
+			Context("when the stemcell is a virtual guest device template group", func() {
+				BeforeEach(func() {
+					stemcell = bslcstem.NewSoftLayerStemcell(1234, "fake-stemcell-uuid", bslcstem.VirtualGuestDeviceTemplateGroupKind, softLayerClient, logger)
+
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
+				})
+
+				It("creates the VM when the stemcell is available in its datacenter", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte(`[{"id": 265592, "name": "ams01"}]`))
+					setFakeSoftLayerClientCreateObjectTestFixtures(softLayerClient)
+
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.ID()).To(Equal(1234567))
+				})
+
+				It("returns a StemcellNotInDatacenterError when the stemcell is not available in its datacenter", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte(`[{"id": 138124, "name": "dal05"}]`))
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(Equal(StemcellNotInDatacenterError{StemcellId: 1234, Datacenter: "ams01", Datacenters: []string{"dal05"}}))
+				})
+
+				Context("when stemcells are replicated", func() {
+					BeforeEach(func() {
+						creator = NewSoftLayerCreator(softLayerClient, agentEnvServiceFactory, agentOptions, waitOptions, false, true, logger)
+					})
+
+					It("replicates the stemcell to its datacenter and waits for it to be active before creating the VM", func() {
+						softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}, {"id": 265592, "name": "ams01"}]`),
+							[]byte("true"),
+							[]byte(`[{"id": 138124, "name": "dal05"}, {"id": 265592, "name": "ams01"}]`),
+						)
+						common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getStatus.json"})
+						setFakeSoftLayerClientCreateObjectTestFixtures(softLayerClient)
+
+						vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(vm.ID()).To(Equal(1234567))
+						Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+					})
+
+					It("waits for the stemcell to be listed in its datacenter before checking that it is active", func() {
+						recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+						creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, true, logger)
+
+						softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}, {"id": 265592, "name": "ams01"}]`),
+							[]byte("true"),
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}, {"id": 265592, "name": "ams01"}]`),
+						)
+						common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service_getStatus.json"})
+						setFakeSoftLayerClientCreateObjectTestFixtures(softLayerClient)
+
+						_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+						Expect(err).ToNot(HaveOccurred())
+
+						paths := []string{}
+						for _, request := range recordingClient.Requests {
+							if strings.HasPrefix(request.Path, "SoftLayer_Virtual_Guest_Block_Device_Template_Group/") {
+								paths = append(paths, request.Path)
+							}
+						}
+						Expect(paths).To(Equal([]string{
+							"SoftLayer_Virtual_Guest_Block_Device_Template_Group/1234/getDatacenters.json",
+							"SoftLayer_Virtual_Guest_Block_Device_Template_Group/1234/addLocations.json",
+							"SoftLayer_Virtual_Guest_Block_Device_Template_Group/1234/getDatacenters.json",
+							"SoftLayer_Virtual_Guest_Block_Device_Template_Group/1234/getDatacenters.json",
+							"SoftLayer_Virtual_Guest_Block_Device_Template_Group/1234/getStatus.json",
+						}))
+					})
+
+					It("returns error when the stemcell is never listed in its datacenter", func() {
+						waitOptions.Create = bslcommon.WaitOptions{Timeout: 2 * time.Millisecond, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+						creator = NewSoftLayerCreator(softLayerClient, agentEnvServiceFactory, agentOptions, waitOptions, false, true, logger)
+
+						softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}, {"id": 265592, "name": "ams01"}]`),
+							[]byte("true"),
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+						)
+
+						_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("to be replicated to datacenter 'ams01'"))
+					})
+
+					It("returns error when the datacenter does not exist", func() {
+						softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+							[]byte(`[{"id": 138124, "name": "dal05"}]`),
+						)
+
+						_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("Datacenter 'ams01' does not exist"))
+					})
+				})
+			})
+
+			Context("when a bare-metal server is requested", func() {
+				BeforeEach(func() {
+					bareMetal := true
+					cloudProps.BareMetal = &bareMetal
+					cloudProps.Os = "UBUNTU_LATEST"
+					stemcell = bslcstem.NewSoftLayerStemcell(1234, "fake-stemcell-uuid", bslcstem.VirtualGuestDeviceTemplateGroupKind, softLayerClient, logger)
+					cloudProps.MaxMemory = 4096
+					cloudProps.RootDiskSize = 500
+
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					fileNames := []string{
+						"SoftLayer_Hardware_Service_createObject.json",
+						"SoftLayer_Hardware_Service_getObject.json",
+						"SoftLayer_Hardware_Service_getObject.json",
+					}
+					common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+				})
+
+				It("orders the server, reloads it with the stemcell and returns a SoftLayerHardwareVM", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+						[]byte("true"),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 1}}`),
+						[]byte(`"fake-reload-message"`),
+						[]byte(`{"activeTransactionCount": 1, "lastOperatingSystemReload": {"id": 1}}`),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 2}}`),
+					)
+
+					recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+					creator = NewSoftLayerCreator(recordingClient, agentEnvServiceFactory, agentOptions, waitOptions, false, false, logger)
+
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm).To(BeAssignableToTypeOf(SoftLayerHardwareVM{}))
+					Expect(vm.ID()).To(Equal(-1))
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+
+					paths := []string{}
+					for _, request := range recordingClient.Requests {
+						paths = append(paths, request.Path)
+					}
+					Expect(paths[len(paths)-5:]).To(Equal([]string{
+						"SoftLayer_Hardware_Server/1/setUserMetadata.json",
+						"SoftLayer_Hardware_Server/1/getObject.json",
+						"SoftLayer_Hardware_Server/1/reloadOperatingSystem.json",
+						"SoftLayer_Hardware_Server/1/getObject.json",
+						"SoftLayer_Hardware_Server/1/getObject.json",
+					}))
+
+					body, found := recordingClient.RequestTo("SoftLayer_Hardware_Server/1/reloadOperatingSystem.json")
+					Expect(found).To(BeTrue())
+					Expect(body).To(MatchJSON(`{"parameters": ["FORCE", {"imageTemplateId": 1234}]}`))
+				})
+
+				It("waits for the reload to start before waiting for it to finish", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses,
+						[]byte("true"),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 1}}`),
+						[]byte(`"fake-reload-message"`),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 1}}`),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 2}}`),
+						[]byte(`{"activeTransactionCount": 1, "lastOperatingSystemReload": {"id": 2}}`),
+						[]byte(`{"activeTransactionCount": 0, "lastOperatingSystemReload": {"id": 2}}`),
+					)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+				})
+
+				It("fails before ordering the server when the stemcell is not an image template", func() {
+					stemcell = bslcstem.NewSoftLayerStemcell(1234, "fake-stemcell-uuid", bslcstem.VirtualDiskImageKind, softLayerClient, logger)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Bare-metal servers can only be provisioned from image template stemcells"))
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(0))
+				})
+
+				It("returns an InvalidCloudPropertiesError when MaxMemory is not a whole number of GB", func() {
+					cloudProps.MaxMemory = 4000
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(Equal(bslcommon.InvalidCloudPropertiesError{Problems: []string{
+						"MaxMemory 4000MB is not a whole number of GB, which bare-metal servers are sized in",
+					}}))
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(0))
+				})
+
+				It("cancels the server if the agent environment cannot be set", func() {
+					softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte("false"))
+					fileNames := []string{
+						"SoftLayer_Hardware_Service_getObject.json",
+						"SoftLayer_Billing_Item_Cancellation_Request_Service_createObject.json",
+					}
+					common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Preparing Hardware 'fake-id', which was cancelled"))
+					Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(len(softLayerClient.DoRawHttpRequestResponses)))
+				})
+
+				It("returns the InvalidCloudPropertiesError of the bare-metal creator", func() {
+					cloudProps.Os = ""
+
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(bslcommon.InvalidCloudPropertiesError{}))
+				})
+			})
+
+			Context("when a disk size is not offered", func() {
+				BeforeEach(func() {
+					cloudProps.EphemeralDiskSize = 10
+				})
+
+				It("returns an InvalidCloudPropertiesError listing the available sizes", func() {
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(BeAssignableToTypeOf(bslcommon.InvalidCloudPropertiesError{}))
+					Expect(err.Error()).To(ContainSubstring("EphemeralDiskSize size 10GB is not offered for local disks, available sizes are [25 100 300]"))
+				})
+			})
+
+			Context("when no disk sizes are set", func() {
+				BeforeEach(func() {
+					cloudProps.RootDiskSize = 0
+					cloudProps.EphemeralDiskSize = 0
+				})
+
+				It("returns a new SoftLayerVM", func() {
+					vm, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(vm.ID()).To(Equal(1234567))
+				})
+			})
+
+			Context("when a manual network does not select a VLAN", func() {
+				BeforeEach(func() {
+					networks = Networks{
+						"fake-net": Network{Type: "manual", IP: "10.0.0.1"},
+					}
+				})
+
+				It("returns error", func() {
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Expected manual network 'fake-net' to have a 'vlanId' cloud property"))
+				})
+			})
+
+			Context("when two networks select VLANs in the same network space", func() {
+				BeforeEach(func() {
+					softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
+
+					networks = Networks{
+						"fake-net1": Network{Type: "dynamic", CloudProperties: map[string]interface{}{"vlanId": 524954}},
+						"fake-net2": Network{Type: "dynamic", CloudProperties: map[string]interface{}{"vlanId": 524955}},
+					}
+
+					fileNames := []string{
+						"SoftLayer_Network_Vlan_Service_getObject_Private.json",
+						"SoftLayer_Network_Vlan_Service_getObject_Private.json",
+					}
+					common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+				})
+
+				It("returns error", func() {
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Networks 'fake-net1' and 'fake-net2' both use a PRIVATE VLAN"))
+				})
+			})
+
+			Context("when more than two networks are given", func() {
+				BeforeEach(func() {
+					networks = Networks{
+						"fake-net1": Network{Type: "dynamic"},
+						"fake-net2": Network{Type: "dynamic"},
+						"fake-net3": Network{Type: "dynamic"},
+					}
+				})
+
+				It("returns error", func() {
+					_, err := creator.Create(agentID, stemcell, cloudProps, networks, env)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Expected at most one public and one private network"))
+				})
+			})
 		})
 
 		Context("invalid arguments", func() {
@@ -88,7 +619,7 @@ var _ = Describe("SoftLayerCreator", func() {
 					networks = Networks{}
 					env = Environment{}
 
-					setFakeSoftLayerClientCreateObjectTestFixtures(softLayerClient)
+					setFakeSoftLayerClientValidateTestFixtures(softLayerClient)
 				})
 
 				It("fails when VMProperties is missing StartCpus", func() {
@@ -152,6 +683,47 @@ var _ = Describe("SoftLayerCreator", func() {
 	})
 })
 
+// sentVirtualGuestTemplate returns the template the virtual guest was ordered with
+func sentVirtualGuestTemplate(client *common.RecordingSoftLayerClient) map[string]interface{} {
+	body, found := client.RequestTo("SoftLayer_Virtual_Guest.json")
+	Expect(found).To(BeTrue())
+
+	var request struct {
+		Parameters []map[string]interface{} `json:"parameters"`
+	}
+	Expect(json.Unmarshal(body, &request)).To(Succeed())
+	Expect(request.Parameters).To(HaveLen(1))
+
+	return request.Parameters[0]
+}
+
+// sentAgentEnv returns the agent env set as user metadata of the virtual guest
+func sentAgentEnv(client *common.RecordingSoftLayerClient, virtualGuestId int) AgentEnv {
+	body, found := client.RequestTo(fmt.Sprintf("SoftLayer_Virtual_Guest/%d/setUserMetadata.json", virtualGuestId))
+	Expect(found).To(BeTrue())
+
+	var request struct {
+		Parameters [][]string `json:"parameters"`
+	}
+	Expect(json.Unmarshal(body, &request)).To(Succeed())
+
+	metadata, err := base64.StdEncoding.DecodeString(request.Parameters[0][0])
+	Expect(err).ToNot(HaveOccurred())
+
+	agentEnv, err := NewAgentEnvFromJSON(metadata)
+	Expect(err).ToNot(HaveOccurred())
+
+	return agentEnv
+}
+
+func setFakeSoftLayerClientValidateTestFixtures(fakeSoftLayerClient *fakeslclient.FakeSoftLayerClient) {
+	fileNames := []string{
+		"SoftLayer_Virtual_Guest_Service_getCreateObjectOptions.json",
+		"SoftLayer_Account_Service_getDatacentersWithSubnetAllocations.json",
+	}
+	common.SetTestFixturesForFakeSoftLayerClient(fakeSoftLayerClient, fileNames)
+}
+
 func setFakeSoftLayerClientCreateObjectTestFixtures(fakeSoftLayerClient *fakeslclient.FakeSoftLayerClient) {
 	fileNames := []string{
 		"SoftLayer_Virtual_Guest_Service_createObject.json",
@@ -163,11 +735,23 @@ func setFakeSoftLayerClientCreateObjectTestFixtures(fakeSoftLayerClient *fakeslc
 		"SoftLayer_Virtual_Guest_Service_configureMetadataDisk.json",
 
 		"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+	}
+	common.SetTestFixturesForFakeSoftLayerClient(fakeSoftLayerClient, fileNames)
+}
+
+func setFakeSoftLayerClientCreateObjectWithNetworksTestFixtures(fakeSoftLayerClient *fakeslclient.FakeSoftLayerClient) {
+	fileNames := []string{
+		"SoftLayer_Virtual_Guest_Service_createObject.json",
+		"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+		"SoftLayer_Virtual_Guest_Service_getNetworkComponents.json",
 
 		"SoftLayer_Virtual_Guest_Service_getPowerState.json",
 		"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
-		"SoftLayer_Virtual_Guest_Service_getUpgradeItemPrices.json",
-		"SoftLayer_Product_Order_Service_placeOrder.json",
+
+		"SoftLayer_Virtual_Guest_Service_setMetadata.json",
+		"SoftLayer_Virtual_Guest_Service_configureMetadataDisk.json",
+
+		"SoftLayer_Virtual_Guest_Service_getPowerState.json",
 	}
 	common.SetTestFixturesForFakeSoftLayerClient(fakeSoftLayerClient, fileNames)
 }
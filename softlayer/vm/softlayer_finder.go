@@ -1,10 +1,17 @@
 package vm
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 )
 
 const softLayerFinderLogTag = "SoftLayerFinder"
@@ -13,19 +20,61 @@ type SoftLayerFinder struct {
 	softLayerClient        sl.Client
 	agentEnvServiceFactory AgentEnvServiceFactory
 
-	logger boshlog.Logger
+	waitOptions bslcommon.OperationWaitOptions
+	scanAccount bool
+	logger      boshlog.Logger
 }
 
-func NewSoftLayerFinder(softLayerClient sl.Client, agentEnvServiceFactory AgentEnvServiceFactory, logger boshlog.Logger) SoftLayerFinder {
+func NewSoftLayerFinder(softLayerClient sl.Client, agentEnvServiceFactory AgentEnvServiceFactory, waitOptions bslcommon.OperationWaitOptions, scanAccount bool, logger boshlog.Logger) SoftLayerFinder {
 	return SoftLayerFinder{
 		softLayerClient:        softLayerClient,
 		agentEnvServiceFactory: agentEnvServiceFactory,
 
-		logger: logger,
+		waitOptions: waitOptions,
+		scanAccount: scanAccount,
+		logger:      logger,
 	}
 }
 
 func (f SoftLayerFinder) Find(vmID int) (VM, bool, error) {
+	if isHardwareCID(vmID) {
+		return f.findHardware(-vmID)
+	}
+
+	if f.scanAccount {
+		return f.findInAccount(vmID)
+	}
+
+	return f.findVirtualGuest(vmID)
+}
+
+// findVirtualGuest treats an empty object or a not found fault as a guest
+// that does not exist
+func (f SoftLayerFinder) findVirtualGuest(vmID int) (VM, bool, error) {
+	virtualGuestService, err := bslcommon.NewFaultDetectingClient(f.softLayerClient).GetSoftLayer_Virtual_Guest_Service()
+	if err != nil {
+		return SoftLayerVM{}, false, bosherr.WrapError(err, "Creating SoftLayer VirtualGuestService from client")
+	}
+
+	virtualGuest, err := virtualGuestService.GetObject(vmID)
+	if err != nil {
+		if _, ok := err.(bslcommon.ObjectNotFoundError); ok {
+			return SoftLayerVM{}, false, nil
+		}
+
+		return SoftLayerVM{}, false, bosherr.WrapError(err, fmt.Sprintf("Getting SoftLayer VirtualGuest `%d` from client", vmID))
+	}
+
+	if virtualGuest.Id != vmID {
+		return SoftLayerVM{}, false, nil
+	}
+
+	return NewSoftLayerVM(vmID, f.softLayerClient, f.agentEnvServiceFactory.New(vmID), f.waitOptions, f.logger), true, nil
+}
+
+// findInAccount scans every virtual guest of the account, which takes a
+// long time on accounts with many guests
+func (f SoftLayerFinder) findInAccount(vmID int) (VM, bool, error) {
 	accountService, err := f.softLayerClient.GetSoftLayer_Account_Service()
 	if err != nil {
 		return SoftLayerVM{}, false, bosherr.WrapError(err, "Creating SoftLayer AcccountService from client")
@@ -39,7 +88,7 @@ func (f SoftLayerFinder) Find(vmID int) (VM, bool, error) {
 	found, vm := false, SoftLayerVM{}
 	for _, virtualGuest := range virtualGuests {
 		if virtualGuest.Id == vmID {
-			vm = NewSoftLayerVM(vmID, f.softLayerClient, f.agentEnvServiceFactory.New(vmID), f.logger)
+			vm = NewSoftLayerVM(vmID, f.softLayerClient, f.agentEnvServiceFactory.New(vmID), f.waitOptions, f.logger)
 			found = true
 			break
 		}
@@ -47,3 +96,52 @@ func (f SoftLayerFinder) Find(vmID int) (VM, bool, error) {
 
 	return vm, found, nil
 }
+
+// findHardware treats an empty object or a not found fault as a server
+// that does not exist
+func (f SoftLayerFinder) findHardware(hardwareId int) (VM, bool, error) {
+	client := bslcommon.NewFaultDetectingClient(f.softLayerClient)
+
+	response, err := client.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Hardware/%d.json", hardwareId), []string{"id"}, "GET", new(bytes.Buffer))
+	if err != nil {
+		if _, ok := err.(bslcommon.ObjectNotFoundError); ok {
+			return SoftLayerHardwareVM{}, false, nil
+		}
+
+		return SoftLayerHardwareVM{}, false, bosherr.WrapError(err, fmt.Sprintf("Getting SoftLayer Hardware `%d` from client", hardwareId))
+	}
+
+	hardware := sldatatypes.SoftLayer_Hardware{}
+	err = json.Unmarshal(response, &hardware)
+	if err != nil {
+		return SoftLayerHardwareVM{}, false, bosherr.WrapError(err, "Unmarshalling SoftLayer Hardware")
+	}
+
+	if hardware.Id != hardwareId {
+		return SoftLayerHardwareVM{}, false, nil
+	}
+
+	return NewSoftLayerHardwareVM(hardwareId, f.softLayerClient, f.waitOptions, f.logger), true, nil
+}
+
+func (f SoftLayerFinder) FindByPrimaryIPs(ips []string) (VM, bool, error) {
+	accountService, err := f.softLayerClient.GetSoftLayer_Account_Service()
+	if err != nil {
+		return SoftLayerVM{}, false, bosherr.WrapError(err, "Creating SoftLayer AcccountService from client")
+	}
+
+	virtualGuests, err := accountService.GetVirtualGuests()
+	if err != nil {
+		return SoftLayerVM{}, false, bosherr.WrapError(err, "Getting a list of SoftLayer VirtualGuests from client")
+	}
+
+	for _, ip := range ips {
+		for _, virtualGuest := range virtualGuests {
+			if virtualGuest.PrimaryBackendIpAddress == ip || virtualGuest.PrimaryIpAddress == ip {
+				return NewSoftLayerVM(virtualGuest.Id, f.softLayerClient, f.agentEnvServiceFactory.New(virtualGuest.Id), f.waitOptions, f.logger), true, nil
+			}
+		}
+	}
+
+	return SoftLayerVM{}, false, nil
+}
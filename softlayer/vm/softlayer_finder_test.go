@@ -1,12 +1,15 @@
 package vm_test
 
 import (
+	"errors"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
 	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
@@ -30,6 +33,8 @@ var _ = Describe("SoftLayerFinder", func() {
 		finder = NewSoftLayerFinder(
 			softLayerClient,
 			agentEnvServiceFactory,
+			bslcommon.OperationWaitOptions{},
+			false,
 			logger,
 		)
 
@@ -43,7 +48,8 @@ var _ = Describe("SoftLayerFinder", func() {
 
 		Context("when the VM ID is valid and existing", func() {
 			BeforeEach(func() {
-				vmID = 5816394
+				vmID = 1234
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getObject.json")
 			})
 
 			It("finds and returns a new SoftLayerVM object with correct ID", func() {
@@ -52,13 +58,144 @@ var _ = Describe("SoftLayerFinder", func() {
 				Expect(found).To(BeTrue(), "could not find VM")
 				Expect(vm.ID()).To(Equal(vmID), "found VM but ID does not match")
 			})
+
+			It("gets the virtual guest directly instead of listing the account", func() {
+				recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+				finder = NewSoftLayerFinder(recordingClient, agentEnvServiceFactory, bslcommon.OperationWaitOptions{}, false, logger)
+
+				_, found, err := finder.Find(vmID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+
+				Expect(recordingClient.Requests).To(HaveLen(1))
+				Expect(recordingClient.Requests[0].Path).To(Equal("SoftLayer_Virtual_Guest/1234/getObject.json"))
+			})
 		})
 
 		Context("when the VM ID does not exist", func() {
-			It("fails finding the VM", func() {
+			It("does not find a VM SoftLayer returns an empty object for", func() {
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getEmptyObject.json")
+
+				_, found, err := finder.Find(1234567)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+
+			It("does not find a VM SoftLayer returns a not found fault for", func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "Unable to find object with id of '1234567'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+
+				_, found, err := finder.Find(1234567)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+		})
+
+		Context("when SoftLayer returns any other fault", func() {
+			It("returns an error instead of not finding the VM", func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "fake-fault", "code": "SoftLayer_Exception_Public"}`)
+				softLayerClient.CheckForHttpResponseError = errors.New("fake-fault")
+
+				_, found, err := finder.Find(1234567)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-fault"))
+				Expect(found).To(BeFalse())
+			})
+		})
+
+		Context("when scanning the account for VMs", func() {
+			BeforeEach(func() {
+				finder = NewSoftLayerFinder(
+					softLayerClient,
+					agentEnvServiceFactory,
+					bslcommon.OperationWaitOptions{},
+					true,
+					logger,
+				)
+			})
+
+			It("finds and returns a new SoftLayerVM object with correct ID", func() {
+				vm, found, err := finder.Find(5816394)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue(), "could not find VM")
+				Expect(vm.ID()).To(Equal(5816394), "found VM but ID does not match")
+			})
+
+			It("fails finding the VM when the VM ID does not exist", func() {
 				_, found, _ := finder.Find(000000)
 				Expect(found).To(BeFalse())
 			})
 		})
+
+		Context("when the VM ID is a bare-metal server CID", func() {
+			It("finds and returns a SoftLayerHardwareVM", func() {
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_getObject.json")
+
+				vm, found, err := finder.Find(-1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(vm).To(BeAssignableToTypeOf(SoftLayerHardwareVM{}))
+				Expect(vm.ID()).To(Equal(-1))
+			})
+
+			It("does not find a server SoftLayer returns an empty object for", func() {
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Hardware_Service_getObject_None_Exist.json")
+
+				_, found, err := finder.Find(-1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+
+			It("does not find a server SoftLayer returns a not found fault for", func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "Unable to find object with id of '1'.", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+
+				_, found, err := finder.Find(-1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+
+			It("returns error when SoftLayer answers with any other fault", func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte(`{"error": "fake-error", "code": "SoftLayer_Exception_Public"}`)
+				softLayerClient.CheckForHttpResponseError = errors.New("fake-response-err")
+
+				_, found, err := finder.Find(-1)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Getting SoftLayer Hardware `1` from client"))
+				Expect(err.Error()).To(ContainSubstring("fake-response-err"))
+				Expect(found).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("FindByPrimaryIPs", func() {
+		It("finds the VM with the given primary backend IP", func() {
+			vm, found, err := finder.FindByPrimaryIPs([]string{"10.106.192.42"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(vm.ID()).To(Equal(5816394))
+		})
+
+		It("finds the VM with the given primary public IP", func() {
+			vm, found, err := finder.FindByPrimaryIPs([]string{"5.153.43.43"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(vm.ID()).To(Equal(5820228))
+		})
+
+		It("finds the VM of the first IP that matches while listing the VMs once", func() {
+			softLayerClient.DoRawHttpRequestResponse = nil
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Account_Service_getVirtualGuests.json"})
+
+			vm, found, err := finder.FindByPrimaryIPs([]string{"10.0.0.1", "5.153.43.43", "10.106.192.42"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(vm.ID()).To(Equal(5820228))
+			Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(1))
+		})
+
+		It("does not find a VM when no VM has any of the given IPs", func() {
+			_, found, err := finder.FindByPrimaryIPs([]string{"10.0.0.1", "10.0.0.2"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
 	})
 })
@@ -0,0 +1,212 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	bslcdisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk"
+)
+
+const softLayerHardwareVMtag = "SoftLayerHardwareVM"
+
+// Bare-metal servers share the integer CID space with virtual guests, so
+// their CIDs are their hardware IDs negated
+func hardwareCID(hardwareId int) int { return -hardwareId }
+
+func isHardwareCID(cid int) bool { return cid < 0 }
+
+// SoftLayerHardwareVM is a VM backed by a bare-metal server
+type SoftLayerHardwareVM struct {
+	hardwareId int
+
+	softLayerClient sl.Client
+	waitOptions     bslcommon.OperationWaitOptions
+	logger          boshlog.Logger
+}
+
+func NewSoftLayerHardwareVM(hardwareId int, softLayerClient sl.Client, waitOptions bslcommon.OperationWaitOptions, logger boshlog.Logger) SoftLayerHardwareVM {
+	return SoftLayerHardwareVM{
+		hardwareId: hardwareId,
+
+		softLayerClient: softLayerClient,
+		waitOptions:     waitOptions,
+		logger:          logger,
+	}
+}
+
+func (vm SoftLayerHardwareVM) ID() int { return hardwareCID(vm.hardwareId) }
+
+func (vm SoftLayerHardwareVM) Delete() error {
+	err := bslcbm.NewBaremetalCreator(vm.softLayerClient, vm.logger).Cancel(strconv.Itoa(vm.hardwareId), vm.waitOptions.BareMetal)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Cancelling Hardware `%d`", vm.hardwareId))
+	}
+
+	return nil
+}
+
+func (vm SoftLayerHardwareVM) Reboot() error {
+	response, err := vm.softLayerClient.DoRawHttpRequest(fmt.Sprintf("SoftLayer_Hardware_Server/%d/rebootSoft.json", vm.hardwareId), "GET", new(bytes.Buffer))
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Rebooting (soft) Hardware `%d`", vm.hardwareId))
+	}
+
+	if res := string(response); res != "true" {
+		return bosherr.Errorf("Failed to reboot (soft) Hardware `%d`, got '%s' as response from the API", vm.hardwareId, res)
+	}
+
+	return nil
+}
+
+func (vm SoftLayerHardwareVM) SetMetadata(vmMetadata VMMetadata) error {
+	if len(vmMetadata) == 0 {
+		return nil
+	}
+
+	err := setMetadataTags(vm.softLayerClient, vm.objectPath(), vmMetadata)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Setting tags on Hardware `%d`", vm.hardwareId))
+	}
+
+	return nil
+}
+
+// ConfigureNetworks is not supported since SoftLayer assigns the networks of
+// a bare-metal server when it is ordered
+func (vm SoftLayerHardwareVM) ConfigureNetworks(networks Networks) error {
+	return NotSupportedError{}
+}
+
+func (vm SoftLayerHardwareVM) AttachDisk(disk bslcdisk.Disk) error {
+	return NotSupportedError{}
+}
+
+func (vm SoftLayerHardwareVM) DetachDisk(disk bslcdisk.Disk) error {
+	return NotSupportedError{}
+}
+
+// GetDisks returns no disks since persistent disks cannot be attached to
+// bare-metal servers
+func (vm SoftLayerHardwareVM) GetDisks() ([]int, error) {
+	return []int{}, nil
+}
+
+// setAgentEnv hands the agent environment to the server through its user
+// metadata, as is done for virtual guests
+func (vm SoftLayerHardwareVM) setAgentEnv(agentEnv AgentEnv) error {
+	metadata, err := json.Marshal(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling agent environment metadata")
+	}
+
+	err = bslcommon.SetUserMetadata(vm.softLayerClient, vm.objectPath(), string(metadata))
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Setting metadata on Hardware `%d`", vm.hardwareId))
+	}
+
+	return nil
+}
+
+// reloadOperatingSystem replaces the operating system the server was ordered
+// with by the given image template and waits for the reload to finish.
+// SoftLayer takes a while to start a reload, so the server is only taken to
+// be reloaded after a transaction showed up or a new reload was recorded,
+// and then no transactions are left.
+func (vm SoftLayerHardwareVM) reloadOperatingSystem(imageTemplateId int) error {
+	before, err := vm.getReloadState()
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Getting last reload of Hardware `%d`", vm.hardwareId))
+	}
+
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"parameters": []interface{}{"FORCE", map[string]int{"imageTemplateId": imageTemplateId}},
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling reloadOperatingSystem parameters")
+	}
+
+	response, err := vm.softLayerClient.DoRawHttpRequest(fmt.Sprintf("%s/reloadOperatingSystem.json", vm.objectPath()), "POST", bytes.NewBuffer(requestBody))
+	if err == nil {
+		err = vm.softLayerClient.CheckForHttpResponseErrors(response)
+	}
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Reloading Hardware `%d` with image template `%d`", vm.hardwareId, imageTemplateId))
+	}
+
+	started, err := vm.waitOptions.BareMetal.Poll(func() (bool, error) {
+		state, err := vm.getReloadState()
+		if err != nil {
+			return false, err
+		}
+
+		return state.ActiveTransactionCount > 0 || state.LastOperatingSystemReload.Id != before.LastOperatingSystemReload.Id, nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for Hardware `%d` to start reloading", vm.hardwareId))
+	}
+
+	if !started {
+		return bosherr.Errorf("Hardware `%d` did not start reloading within %s", vm.hardwareId, vm.waitOptions.BareMetal.Timeout)
+	}
+
+	done, err := vm.waitOptions.BareMetal.Poll(func() (bool, error) {
+		state, err := vm.getReloadState()
+		if err != nil {
+			return false, err
+		}
+
+		return state.ActiveTransactionCount == 0, nil
+	})
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for Hardware `%d` to be reloaded", vm.hardwareId))
+	}
+
+	if !done {
+		return bosherr.Errorf("Hardware `%d` was not reloaded within %s", vm.hardwareId, vm.waitOptions.BareMetal.Timeout)
+	}
+
+	return nil
+}
+
+type hardwareReloadState struct {
+	ActiveTransactionCount int `json:"activeTransactionCount"`
+
+	LastOperatingSystemReload struct {
+		Id int `json:"id"`
+	} `json:"lastOperatingSystemReload"`
+}
+
+func (vm SoftLayerHardwareVM) getReloadState() (hardwareReloadState, error) {
+	objectMask := []string{"activeTransactionCount", "lastOperatingSystemReload.id"}
+
+	response, err := vm.softLayerClient.DoRawHttpRequestWithObjectMask(fmt.Sprintf("%s/getObject.json", vm.objectPath()), objectMask, "GET", new(bytes.Buffer))
+	if err != nil {
+		return hardwareReloadState{}, err
+	}
+
+	err = vm.softLayerClient.CheckForHttpResponseErrors(response)
+	if err != nil {
+		return hardwareReloadState{}, err
+	}
+
+	state := hardwareReloadState{}
+	err = json.Unmarshal(response, &state)
+	if err != nil {
+		return hardwareReloadState{}, bosherr.WrapError(err, "Unmarshalling active transactions and last reload")
+	}
+
+	return state, nil
+}
+
+func (vm SoftLayerHardwareVM) objectPath() string {
+	return fmt.Sprintf("SoftLayer_Hardware_Server/%d", vm.hardwareId)
+}
@@ -0,0 +1,115 @@
+package vm_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+
+	boshlog "github.com/cloudfoundry/bosh-agent/logger"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	fakedisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk/fakes"
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+)
+
+var _ = Describe("SoftLayerHardwareVM", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		waitOptions     bslcommon.OperationWaitOptions
+		vm              SoftLayerHardwareVM
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+		bareMetalWaitOptions := bslcommon.WaitOptions{Timeout: 1 * time.Second, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+		waitOptions = bslcommon.OperationWaitOptions{BareMetal: bareMetalWaitOptions}
+
+		vm = NewSoftLayerHardwareVM(1234, softLayerClient, waitOptions, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	It("has a CID distinguishable from virtual guest CIDs", func() {
+		Expect(vm.ID()).To(Equal(-1234))
+	})
+
+	Describe("Delete", func() {
+		It("cancels the billing item of the server", func() {
+			fileNames := []string{
+				"SoftLayer_Hardware_Service_getObject.json",
+				"SoftLayer_Billing_Item_Cancellation_Request_Service_createObject.json",
+			}
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+
+			recordingClient := common.NewRecordingSoftLayerClient(softLayerClient)
+			vm = NewSoftLayerHardwareVM(1234, recordingClient, waitOptions, boshlog.NewLogger(boshlog.LevelNone))
+
+			err := vm.Delete()
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found := recordingClient.RequestTo("SoftLayer_Hardware/1234.json")
+			Expect(found).To(BeTrue())
+
+			body, found := recordingClient.RequestTo("SoftLayer_Billing_Item_Cancellation_Request/createObject.json")
+			Expect(found).To(BeTrue())
+			Expect(string(body)).To(ContainSubstring(`"billingItemId":1234567`))
+		})
+
+		It("returns error if SoftLayer does not cancel the server", func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Hardware_Service_getObject.json"})
+			softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte(`{"error": "fake-error", "code": "SoftLayer_Exception_Public"}`))
+
+			err := vm.Delete()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Cancelling Hardware `1234`"))
+		})
+	})
+
+	Describe("Reboot", func() {
+		It("soft reboots the server", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("true")
+
+			err := vm.Reboot()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error if SoftLayer does not reboot the server", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("false")
+
+			err := vm.Reboot()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Failed to reboot (soft) Hardware `1234`"))
+		})
+	})
+
+	Describe("SetMetadata", func() {
+		It("sets the metadata as tags", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("true")
+
+			err := vm.SetMetadata(VMMetadata{"job": "fake-job", "index": "0"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error if SoftLayer does not set the tags", func() {
+			softLayerClient.DoRawHttpRequestResponse = []byte("false")
+
+			err := vm.SetMetadata(VMMetadata{"job": "fake-job"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Setting tags on Hardware `1234`"))
+		})
+	})
+
+	It("does not support changing networks or persistent disks", func() {
+		Expect(vm.ConfigureNetworks(Networks{})).To(Equal(NotSupportedError{}))
+		Expect(vm.AttachDisk(fakedisk.NewFakeDisk(1))).To(Equal(NotSupportedError{}))
+		Expect(vm.DetachDisk(fakedisk.NewFakeDisk(1))).To(Equal(NotSupportedError{}))
+
+		disks, err := vm.GetDisks()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(disks).To(BeEmpty())
+	})
+})
@@ -0,0 +1,276 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+	sl "github.com/maximilien/softlayer-go/softlayer"
+)
+
+const (
+	publicNetworkSpace  = "PUBLIC"
+	privateNetworkSpace = "PRIVATE"
+)
+
+// virtualGuestTemplate extends the softlayer-go template so that a primary
+// subnet can be selected along with the VLAN of each network component
+type virtualGuestTemplate struct {
+	sldatatypes.SoftLayer_Virtual_Guest_Template
+
+	PrimaryNetworkComponent        *networkComponentTemplate `json:"primaryNetworkComponent,omitempty"`
+	PrimaryBackendNetworkComponent *networkComponentTemplate `json:"primaryBackendNetworkComponent,omitempty"`
+}
+
+type networkComponentTemplate struct {
+	NetworkVlan networkVlanTemplate `json:"networkVlan"`
+}
+
+type networkVlanTemplate struct {
+	Id            int             `json:"id"`
+	PrimarySubnet *subnetTemplate `json:"primarySubnet,omitempty"`
+}
+
+type subnetTemplate struct {
+	Id int `json:"id"`
+}
+
+type networkVlan struct {
+	Id           int    `json:"id"`
+	NetworkSpace string `json:"networkSpace"`
+}
+
+type virtualGuestNetworkComponents struct {
+	PrimaryNetworkComponent        *virtualGuestNetworkComponent `json:"primaryNetworkComponent"`
+	PrimaryBackendNetworkComponent *virtualGuestNetworkComponent `json:"primaryBackendNetworkComponent"`
+}
+
+type virtualGuestNetworkComponent struct {
+	MacAddress       string `json:"macAddress"`
+	PrimaryIpAddress string `json:"primaryIpAddress"`
+
+	NetworkVlan *struct {
+		Id int `json:"id"`
+	} `json:"networkVlan"`
+
+	PrimarySubnet *struct {
+		Netmask string `json:"netmask"`
+		Gateway string `json:"gateway"`
+	} `json:"primarySubnet"`
+}
+
+// vlanSpaceFunc returns the network space of the VLAN a network selects, or
+// an empty space when the network does not select a VLAN
+type vlanSpaceFunc func(netName string, network Network, cloudProps NetworkCloudProperties) (string, error)
+
+// matchNetworkSpaces decides which network component (public or private)
+// backs each network. Networks with a VLAN take the space vlanSpace returns
+// for it; networks without one take the private component first, then the
+// public one.
+func matchNetworkSpaces(networks Networks, vlanSpace vlanSpaceFunc) (map[string]string, error) {
+	netNames := []string{}
+	for netName := range networks {
+		netNames = append(netNames, netName)
+	}
+	sort.Strings(netNames)
+
+	spaces := map[string]string{}
+	claimed := map[string]string{}
+
+	unassigned := []string{}
+	for _, netName := range netNames {
+		network := networks[netName]
+		if network.Type == "vip" {
+			continue
+		}
+
+		cloudProps, err := network.SoftLayerCloudProperties()
+		if err != nil {
+			return spaces, bosherr.WrapErrorf(err, "Reading cloud properties of network '%s'", netName)
+		}
+
+		space, err := vlanSpace(netName, network, cloudProps)
+		if err != nil {
+			return spaces, err
+		}
+
+		if space == "" {
+			unassigned = append(unassigned, netName)
+			continue
+		}
+
+		if otherNetName, found := claimed[space]; found {
+			return spaces, bosherr.Errorf("Networks '%s' and '%s' both use a %s VLAN", otherNetName, netName, space)
+		}
+
+		claimed[space] = netName
+		spaces[netName] = space
+	}
+
+	for _, netName := range unassigned {
+		switch {
+		case claimed[privateNetworkSpace] == "":
+			claimed[privateNetworkSpace] = netName
+			spaces[netName] = privateNetworkSpace
+		case claimed[publicNetworkSpace] == "":
+			claimed[publicNetworkSpace] = netName
+			spaces[netName] = publicNetworkSpace
+		default:
+			return spaces, bosherr.Errorf("Expected at most one public and one private network; cannot assign network '%s'", netName)
+		}
+	}
+
+	return spaces, nil
+}
+
+// assignNetworkSpaces matches networks onto the network components of a
+// virtual guest to be ordered and selects VLANs and subnets in the template
+// accordingly
+func (c SoftLayerCreator) assignNetworkSpaces(networks Networks, template *virtualGuestTemplate) (map[string]string, error) {
+	return matchNetworkSpaces(networks, func(netName string, network Network, cloudProps NetworkCloudProperties) (string, error) {
+		if cloudProps.VlanId == 0 {
+			if network.IsManual() {
+				return "", bosherr.Errorf("Expected manual network '%s' to have a 'vlanId' cloud property", netName)
+			}
+
+			return "", nil
+		}
+
+		vlan, err := c.getNetworkVlan(cloudProps.VlanId)
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Getting VLAN `%d` of network '%s'", cloudProps.VlanId, netName)
+		}
+
+		component := &networkComponentTemplate{NetworkVlan: networkVlanTemplate{Id: vlan.Id}}
+		if cloudProps.SubnetId != 0 {
+			component.NetworkVlan.PrimarySubnet = &subnetTemplate{Id: cloudProps.SubnetId}
+		}
+
+		switch vlan.NetworkSpace {
+		case publicNetworkSpace:
+			template.PrimaryNetworkComponent = component
+		case privateNetworkSpace:
+			template.PrimaryBackendNetworkComponent = component
+		default:
+			return "", bosherr.Errorf("Unexpected network space '%s' for VLAN `%d` of network '%s'", vlan.NetworkSpace, vlan.Id, netName)
+		}
+
+		return vlan.NetworkSpace, nil
+	})
+}
+
+// assignExistingNetworkSpaces maps networks onto the network components a
+// virtual guest already has. Moving a guest to another VLAN cannot be done
+// in place, so a VLAN that none of its components is on is not supported.
+func assignExistingNetworkSpaces(networks Networks, components virtualGuestNetworkComponents) (map[string]string, error) {
+	return matchNetworkSpaces(networks, func(netName string, network Network, cloudProps NetworkCloudProperties) (string, error) {
+		switch cloudProps.VlanId {
+		case 0:
+			return "", nil
+		case components.PrimaryNetworkComponent.vlanId():
+			return publicNetworkSpace, nil
+		case components.PrimaryBackendNetworkComponent.vlanId():
+			return privateNetworkSpace, nil
+		default:
+			return "", NotSupportedError{}
+		}
+	})
+}
+
+// resolveNetworks returns networks updated with the IP, netmask, gateway and
+// MAC address that SoftLayer assigned to the matching network components. A
+// manual network must have been assigned the IP the director reserved for it.
+func resolveNetworks(virtualGuestId int, networks Networks, spaces map[string]string, components virtualGuestNetworkComponents) (Networks, error) {
+	resolved := Networks{}
+	for netName, network := range networks {
+		space, found := spaces[netName]
+		if !found {
+			resolved[netName] = network
+			continue
+		}
+
+		component := components.PrimaryBackendNetworkComponent
+		if space == publicNetworkSpace {
+			component = components.PrimaryNetworkComponent
+		}
+
+		if component == nil || component.PrimaryIpAddress == "" {
+			return networks, bosherr.Errorf("Expected VirtualGuest `%d` to have a %s network component for network '%s'", virtualGuestId, space, netName)
+		}
+
+		if network.IsManual() && network.IP != "" && network.IP != component.PrimaryIpAddress {
+			return networks, bosherr.Errorf("Manual network '%s' of VirtualGuest `%d` requires IP '%s' but SoftLayer assigned '%s'", netName, virtualGuestId, network.IP, component.PrimaryIpAddress)
+		}
+
+		network.IP = component.PrimaryIpAddress
+		network.MAC = component.MacAddress
+
+		if component.PrimarySubnet != nil {
+			network.Netmask = component.PrimarySubnet.Netmask
+			network.Gateway = component.PrimarySubnet.Gateway
+		}
+
+		resolved[netName] = network
+	}
+
+	return resolved, nil
+}
+
+func (c SoftLayerCreator) getNetworkVlan(vlanId int) (networkVlan, error) {
+	response, err := c.softLayerClient.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Network_Vlan/%d/getObject.json", vlanId), []string{"id", "networkSpace"}, "GET", new(bytes.Buffer))
+	if err != nil {
+		return networkVlan{}, err
+	}
+
+	vlan := networkVlan{}
+	err = json.Unmarshal(response, &vlan)
+	if err != nil {
+		return networkVlan{}, bosherr.WrapError(err, "Unmarshalling VLAN")
+	}
+
+	if vlan.Id == 0 {
+		return networkVlan{}, bosherr.Errorf("VLAN `%d` not found", vlanId)
+	}
+
+	return vlan, nil
+}
+
+func getNetworkComponents(softLayerClient sl.Client, virtualGuestId int) (virtualGuestNetworkComponents, error) {
+	objectMask := []string{
+		"primaryNetworkComponent.networkVlan.id",
+		"primaryNetworkComponent.macAddress",
+		"primaryNetworkComponent.primaryIpAddress",
+		"primaryNetworkComponent.primarySubnet.netmask",
+		"primaryNetworkComponent.primarySubnet.gateway",
+		"primaryBackendNetworkComponent.networkVlan.id",
+		"primaryBackendNetworkComponent.macAddress",
+		"primaryBackendNetworkComponent.primaryIpAddress",
+		"primaryBackendNetworkComponent.primarySubnet.netmask",
+		"primaryBackendNetworkComponent.primarySubnet.gateway",
+	}
+
+	response, err := softLayerClient.DoRawHttpRequestWithObjectMask(fmt.Sprintf("SoftLayer_Virtual_Guest/%d/getObject.json", virtualGuestId), objectMask, "GET", new(bytes.Buffer))
+	if err != nil {
+		return virtualGuestNetworkComponents{}, err
+	}
+
+	components := virtualGuestNetworkComponents{}
+	err = json.Unmarshal(response, &components)
+	if err != nil {
+		return virtualGuestNetworkComponents{}, bosherr.WrapError(err, "Unmarshalling network components")
+	}
+
+	return components, nil
+}
+
+func (c *virtualGuestNetworkComponent) vlanId() int {
+	if c == nil || c.NetworkVlan == nil {
+		return -1
+	}
+
+	return c.NetworkVlan.Id
+}
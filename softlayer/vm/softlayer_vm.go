@@ -1,13 +1,15 @@
 package vm
 
 import (
-	"encoding/json"
 	"fmt"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-agent/errors"
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
 	sl "github.com/maximilien/softlayer-go/softlayer"
 
 	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
@@ -22,20 +24,19 @@ type SoftLayerVM struct {
 	softLayerClient sl.Client
 	agentEnvService AgentEnvService
 
-	logger boshlog.Logger
+	waitOptions bslcommon.OperationWaitOptions
+	logger      boshlog.Logger
 }
 
-func NewSoftLayerVM(id int, softLayerClient sl.Client, agentEnvService AgentEnvService, logger boshlog.Logger) SoftLayerVM {
-	bslcommon.TIMEOUT = 10 * time.Minute
-	bslcommon.POLLING_INTERVAL = 10 * time.Second
-
+func NewSoftLayerVM(id int, softLayerClient sl.Client, agentEnvService AgentEnvService, waitOptions bslcommon.OperationWaitOptions, logger boshlog.Logger) SoftLayerVM {
 	return SoftLayerVM{
 		id: id,
 
 		softLayerClient: softLayerClient,
 		agentEnvService: agentEnvService,
 
-		logger: logger,
+		waitOptions: waitOptions,
+		logger:      logger,
 	}
 }
 
@@ -47,6 +48,12 @@ func (vm SoftLayerVM) Delete() error {
 		return bosherr.WrapError(err, "Creating SoftLayer VirtualGuestService from client")
 	}
 
+	// SoftLayer refuses to delete a VirtualGuest with pending transactions
+	err = bslcommon.WaitForVirtualGuestToHaveNoRunningTransactions(vm.softLayerClient, vm.ID(), vm.waitOptions.Delete)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", vm.ID()))
+	}
+
 	deleted, err := virtualGuestService.DeleteObject(vm.ID())
 	if err != nil {
 		return bosherr.WrapError(err, "Deleting SoftLayer VirtualGuest from client")
@@ -78,32 +85,243 @@ func (vm SoftLayerVM) Reboot() error {
 }
 
 func (vm SoftLayerVM) SetMetadata(vmMetadata VMMetadata) error {
-	//TODO: reconcile this metadata (from VMMetadata) abd SoftLayerCreator.Run metadata
-	metadata, err := json.Marshal(vmMetadata)
+	if len(vmMetadata) == 0 {
+		return nil
+	}
+
+	err := setMetadataTags(vm.softLayerClient, fmt.Sprintf("SoftLayer_Virtual_Guest/%d", vm.ID()), vmMetadata)
 	if err != nil {
-		return bosherr.WrapError(err, "Marshalling VM metadata")
+		return bosherr.WrapError(err, fmt.Sprintf("Setting tags on VirtualGuest `%d`", vm.ID()))
+	}
+
+	job, index := vmMetadata["job"], vmMetadata["index"]
+	if job == "" || index == "" {
+		return nil
 	}
 
-	err = bslcommon.ConfigureMetadataOnVirtualGuest(vm.softLayerClient, vm.id, string(metadata), bslcommon.TIMEOUT, bslcommon.POLLING_INTERVAL)
+	virtualGuestService, err := vm.softLayerClient.GetSoftLayer_Virtual_Guest_Service()
 	if err != nil {
-		return bosherr.WrapError(err, fmt.Sprintf("Configuring metadata on VirtualGuest `%d`", vm.id))
+		return bosherr.WrapError(err, "Creating SoftLayer VirtualGuestService from client")
+	}
+
+	name := fmt.Sprintf("%s-%s", strings.Replace(job, "_", "-", -1), index)
+
+	edited, err := virtualGuestService.EditObject(vm.ID(), sldatatypes.SoftLayer_Virtual_Guest{
+		Hostname: name,
+		Notes:    name,
+	})
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Editing hostname and notes of VirtualGuest `%d`", vm.ID()))
+	}
+
+	if !edited {
+		return bosherr.Errorf("Did not edit hostname and notes of VirtualGuest `%d`", vm.ID())
 	}
 
 	return nil
 }
 
+// setMetadataTags tags the virtual guest or bare-metal server at objectPath
+// with one "key:value" tag per metadata entry. Commas separate tags, so
+// they are replaced in values.
+func setMetadataTags(softLayerClient sl.Client, objectPath string, vmMetadata VMMetadata) error {
+	tags := []string{}
+	for key, value := range vmMetadata {
+		tags = append(tags, fmt.Sprintf("%s:%s", key, strings.Replace(value, ",", " ", -1)))
+	}
+	sort.Strings(tags)
+
+	return bslcommon.SetTags(softLayerClient, objectPath, tags)
+}
+
 func (vm SoftLayerVM) ConfigureNetworks(networks Networks) error {
-	return NotSupportedError{}
+	components, err := getNetworkComponents(vm.softLayerClient, vm.ID())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting network components of VirtualGuest `%d`", vm.ID())
+	}
+
+	networkSpaces, err := assignExistingNetworkSpaces(networks, components)
+	if err != nil {
+		if _, ok := err.(NotSupportedError); ok {
+			vm.logger.Debug(softLayerVMtag, "Networks of VirtualGuest `%d` require a VLAN change", vm.ID())
+			return err
+		}
+
+		return bosherr.WrapError(err, "Assigning networks")
+	}
+
+	networks, err = resolveNetworks(vm.ID(), networks, networkSpaces, components)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resolving networks of VirtualGuest `%d`", vm.ID())
+	}
+
+	agentEnv, err := vm.agentEnvService.Fetch()
+	if err != nil {
+		return bosherr.WrapError(err, "Fetching agent env")
+	}
+
+	agentEnv = agentEnv.AttachNetworks(networks)
+
+	err = vm.agentEnvService.Update(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Updating agent env")
+	}
+
+	err = vm.Reboot()
+	if err != nil {
+		return bosherr.WrapError(err, "Rebooting VM to apply networks")
+	}
+
+	err = bslcommon.WaitForVirtualGuestToReboot(vm.softLayerClient, vm.ID(), vm.waitOptions.Create)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to reboot", vm.ID()))
+	}
+
+	return nil
 }
 
 func (vm SoftLayerVM) AttachDisk(disk bslcdisk.Disk) error {
-	vm.logger.Info(softLayerVMtag, "Not yet implemented!")
+	virtualGuestService, err := vm.softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+	if err != nil {
+		return bosherr.WrapError(err, "Creating SoftLayer VirtualGuestService from client")
+	}
+
+	vm.logger.Debug(softLayerVMtag, "Attaching iSCSI volume `%d` to VirtualGuest `%d`", disk.ID(), vm.ID())
+
+	deviceName, err := virtualGuestService.AttachIscsiVolume(vm.ID(), disk.ID())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Attaching iSCSI volume `%d` to VirtualGuest `%d`", disk.ID(), vm.ID())
+	}
+
+	err = bslcommon.WaitForVirtualGuestToHaveNoRunningTransactions(vm.softLayerClient, vm.ID(), vm.waitOptions.Attach)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", vm.ID()))
+	}
+
+	agentEnv, err := vm.agentEnvService.Fetch()
+	if err != nil {
+		return bosherr.WrapError(err, "Fetching agent env")
+	}
+
+	agentEnv = agentEnv.AttachPersistentDisk(strconv.Itoa(disk.ID()), devicePath(deviceName))
+
+	err = vm.agentEnvService.Update(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Updating agent env")
+	}
 
 	return nil
 }
 
 func (vm SoftLayerVM) DetachDisk(disk bslcdisk.Disk) error {
-	vm.logger.Info(softLayerVMtag, "Not yet implemented!")
+	agentEnv, err := vm.agentEnvService.Fetch()
+	if err != nil {
+		return bosherr.WrapError(err, "Fetching agent env")
+	}
+
+	diskID := strconv.Itoa(disk.ID())
+	_, inAgentEnv := agentEnv.Disks.Persistent[diskID]
+
+	// The volume may still be authorized even though the agent env does not
+	// know it, e.g. after an attach that failed part way
+	authorized, err := vm.isIscsiVolumeAllowed(disk.ID())
+	if err != nil {
+		return err
+	}
+
+	if !inAgentEnv && !authorized {
+		return DiskNotAttachedError{}
+	}
+
+	if authorized {
+		err = vm.detachIscsiVolume(disk.ID())
+		if err != nil {
+			return err
+		}
+	}
+
+	if !inAgentEnv {
+		return nil
+	}
+
+	agentEnv = agentEnv.DetachPersistentDisk(diskID)
+
+	err = vm.agentEnvService.Update(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Updating agent env")
+	}
+
+	return nil
+}
+
+func (vm SoftLayerVM) isIscsiVolumeAllowed(volumeId int) (bool, error) {
+	volumes, err := bslcommon.GetIscsiVolumesAllowedOnVirtualGuest(vm.softLayerClient, vm.ID())
+	if err != nil {
+		return false, bosherr.WrapErrorf(err, "Getting iSCSI volumes of VirtualGuest `%d`", vm.ID())
+	}
+
+	for _, volume := range volumes {
+		if volume.Id == volumeId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (vm SoftLayerVM) detachIscsiVolume(volumeId int) error {
+	virtualGuestService, err := vm.softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+	if err != nil {
+		return bosherr.WrapError(err, "Creating SoftLayer VirtualGuestService from client")
+	}
+
+	vm.logger.Debug(softLayerVMtag, "Detaching iSCSI volume `%d` from VirtualGuest `%d`", volumeId, vm.ID())
+
+	err = virtualGuestService.DetachIscsiVolume(vm.ID(), volumeId)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Detaching iSCSI volume `%d` from VirtualGuest `%d`", volumeId, vm.ID())
+	}
+
+	err = bslcommon.WaitForVirtualGuestToHaveNoRunningTransactions(vm.softLayerClient, vm.ID(), vm.waitOptions.Attach)
+	if err != nil {
+		return bosherr.WrapError(err, fmt.Sprintf("Waiting for VirtualGuest `%d` to have no pending transactions", vm.ID()))
+	}
 
 	return nil
 }
+
+func (vm SoftLayerVM) GetDisks() ([]int, error) {
+	volumes, err := bslcommon.GetIscsiVolumesAllowedOnVirtualGuest(vm.softLayerClient, vm.ID())
+	if err != nil {
+		return []int{}, bosherr.WrapErrorf(err, "Getting iSCSI volumes of VirtualGuest `%d`", vm.ID())
+	}
+
+	if len(volumes) == 0 {
+		return []int{}, nil
+	}
+
+	agentEnv, err := vm.agentEnvService.Fetch()
+	if err != nil {
+		return []int{}, bosherr.WrapError(err, "Fetching agent env")
+	}
+
+	diskIDs := []int{}
+	for _, volume := range volumes {
+		if _, found := agentEnv.Disks.Persistent[strconv.Itoa(volume.Id)]; !found {
+			vm.logger.Debug(softLayerVMtag, "Ignoring iSCSI volume `%d` not known to the agent env of VirtualGuest `%d`", volume.Id, vm.ID())
+			continue
+		}
+
+		diskIDs = append(diskIDs, volume.Id)
+	}
+
+	return diskIDs, nil
+}
+
+func devicePath(deviceName string) string {
+	if strings.HasPrefix(deviceName, "/dev/") {
+		return deviceName
+	}
+
+	return "/dev/" + deviceName
+}
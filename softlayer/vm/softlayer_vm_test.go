@@ -1,23 +1,32 @@
 package vm_test
 
 import (
+	"errors"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
 
 	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
 
 	boshlog "github.com/cloudfoundry/bosh-agent/logger"
 
+	"github.com/maximilien/bosh-softlayer-cpi/util"
+
+	fakedisk "github.com/maximilien/bosh-softlayer-cpi/softlayer/disk/fakes"
 	fakevm "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm/fakes"
 	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+	sl "github.com/maximilien/softlayer-go/softlayer"
 )
 
 var _ = Describe("SoftLayerVM", func() {
 	var (
 		softLayerClient *fakeslclient.FakeSoftLayerClient
 		agentEnvService *fakevm.FakeAgentEnvService
+		waitOptions     bslcommon.OperationWaitOptions
 		logger          boshlog.Logger
 		vm              SoftLayerVM
 	)
@@ -28,14 +37,26 @@ var _ = Describe("SoftLayerVM", func() {
 		agentEnvService = &fakevm.FakeAgentEnvService{}
 		logger = boshlog.NewLogger(boshlog.LevelNone)
 
-		vm = NewSoftLayerVM(1234, softLayerClient, agentEnvService, logger)
+		fastWaitOptions := bslcommon.WaitOptions{Timeout: 1 * time.Second, PollingInterval: 1 * time.Millisecond, Sleeper: util.NewRecordingNoopSleeper()}
+		waitOptions = bslcommon.OperationWaitOptions{
+			Create:   fastWaitOptions,
+			Metadata: fastWaitOptions,
+			Attach:   fastWaitOptions,
+			Delete:   fastWaitOptions,
+		}
+
+		vm = NewSoftLayerVM(1234, softLayerClient, agentEnvService, waitOptions, logger)
 	})
 
 	Describe("Delete", func() {
+		BeforeEach(func() {
+			common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json"})
+		})
+
 		Context("valid VM ID is used", func() {
 			BeforeEach(func() {
-				softLayerClient.DoRawHttpRequestResponse = []byte("true")
-				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, logger)
+				softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte("true"))
+				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
 			})
 
 			It("deletes the VM successfully", func() {
@@ -46,8 +67,8 @@ var _ = Describe("SoftLayerVM", func() {
 
 		Context("invalid VM ID is used", func() {
 			BeforeEach(func() {
-				softLayerClient.DoRawHttpRequestResponse = []byte("false")
-				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, logger)
+				softLayerClient.DoRawHttpRequestResponses = append(softLayerClient.DoRawHttpRequestResponses, []byte("false"))
+				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, waitOptions, logger)
 			})
 
 			It("fails deleting the VM", func() {
@@ -61,7 +82,7 @@ var _ = Describe("SoftLayerVM", func() {
 		Context("valid VM ID is used", func() {
 			BeforeEach(func() {
 				softLayerClient.DoRawHttpRequestResponse = []byte("true")
-				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, logger)
+				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
 			})
 
 			It("reboots the VM successfully", func() {
@@ -73,7 +94,7 @@ var _ = Describe("SoftLayerVM", func() {
 		Context("invalid VM ID is used", func() {
 			BeforeEach(func() {
 				softLayerClient.DoRawHttpRequestResponse = []byte("false")
-				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, logger)
+				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, waitOptions, logger)
 			})
 
 			It("fails rebooting the VM", func() {
@@ -88,76 +109,411 @@ var _ = Describe("SoftLayerVM", func() {
 			metadata VMMetadata
 		)
 
+		BeforeEach(func() {
+			metadata = VMMetadata{
+				"director":   "fake-director",
+				"deployment": "fake-deployment",
+				"job":        "fake_job",
+				"index":      "0",
+			}
+		})
+
 		Context("valid VM ID is used", func() {
 			BeforeEach(func() {
 				fileNames := []string{
-					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
-					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
-
-					"SoftLayer_Virtual_Guest_Service_setMetadata.json",
-					"SoftLayer_Virtual_Guest_Service_configureMetadataDisk.json",
-
-					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+					"SoftLayer_Virtual_Guest_Service_setTags.json",
+					"SoftLayer_Virtual_Guest_Service_editObject.json",
 				}
 				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
 
-				metadata = VMMetadata{}
-				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, logger)
+				vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
 			})
 
 			It("sets the vm metadata successfully", func() {
 				err := vm.SetMetadata(metadata)
 				Expect(err).ToNot(HaveOccurred())
 			})
+
+			It("does not touch the agent env", func() {
+				err := vm.SetMetadata(metadata)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(agentEnvService.FetchCalled).To(BeFalse())
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}))
+			})
+		})
+
+		Context("when metadata is empty", func() {
+			It("does nothing", func() {
+				err := vm.SetMetadata(VMMetadata{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(0))
+			})
 		})
 
 		Context("invalid VM ID is used", func() {
+			BeforeEach(func() {
+				softLayerClient.DoRawHttpRequestResponse = []byte("false")
+				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, waitOptions, logger)
+			})
+
+			It("fails setting the vm metadata", func() {
+				err := vm.SetMetadata(metadata)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ConfigureNetworks", func() {
+		var (
+			networks Networks
+		)
+
+		BeforeEach(func() {
+			networks = Networks{
+				"fake-private-net": Network{
+					Type:            "dynamic",
+					CloudProperties: map[string]interface{}{"vlanId": 524954},
+				},
+				"fake-public-net": Network{
+					Type: "dynamic",
+				},
+			}
+			agentEnvService.FetchAgentEnv = AgentEnv{AgentID: "fake-agent-id"}
+		})
+
+		Context("when the networks are on the VLANs of the VM", func() {
 			BeforeEach(func() {
 				fileNames := []string{
-					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+					"SoftLayer_Virtual_Guest_Service_getNetworkComponents.json",
+					"SoftLayer_Virtual_Guest_Service_rebootSoft.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions_Reboot.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions_Reboot.json",
 					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+				}
+				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+			})
+
+			It("waits for the reboot transaction to finish and the VM to be running", func() {
+				err := vm.ConfigureNetworks(networks)
+				Expect(err).ToNot(HaveOccurred())
 
-					"SoftLayer_Virtual_Guest_Service_setMetadata_false.json",
-					"SoftLayer_Virtual_Guest_Service_configureMetadataDisk.json",
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(6))
+			})
 
+			It("waits for the queued reboot to start before waiting for the VM to be running", func() {
+				softLayerClient.DoRawHttpRequestResponses = [][]byte{}
+				fileNames := []string{
+					"SoftLayer_Virtual_Guest_Service_getNetworkComponents.json",
+					"SoftLayer_Virtual_Guest_Service_rebootSoft.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+					"SoftLayer_Virtual_Guest_Service_getPowerState_Halted.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+					"SoftLayer_Virtual_Guest_Service_getPowerState_Halted.json",
 					"SoftLayer_Virtual_Guest_Service_getPowerState.json",
 				}
 				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
 
-				metadata = VMMetadata{}
-				vm = NewSoftLayerVM(00000, softLayerClient, agentEnvService, logger)
+				err := vm.ConfigureNetworks(networks)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(softLayerClient.DoRawHttpRequestResponsesIndex).To(Equal(9))
 			})
 
-			It("fails setting the vm metadata", func() {
-				err := vm.SetMetadata(metadata)
+			It("updates the agent env with the networks of the VM", func() {
+				err := vm.ConfigureNetworks(networks)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(agentEnvService.UpdateAgentEnv.AgentID).To(Equal("fake-agent-id"))
+				Expect(agentEnvService.UpdateAgentEnv.Networks).To(Equal(NetworksSpec{
+					"fake-private-net": NetworkSpec{
+						Type:            "dynamic",
+						IP:              "10.106.192.42",
+						Netmask:         "255.255.255.192",
+						Gateway:         "10.106.192.1",
+						MAC:             "06:00:00:00:00:02",
+						CloudProperties: map[string]interface{}{"vlanId": 524954},
+					},
+					"fake-public-net": NetworkSpec{
+						Type:    "dynamic",
+						IP:      "23.246.234.32",
+						Netmask: "255.255.255.240",
+						Gateway: "23.246.234.33",
+						MAC:     "06:00:00:00:00:01",
+					},
+				}))
+			})
+
+			Context("when updating the agent env fails", func() {
+				BeforeEach(func() {
+					agentEnvService.UpdateErr = errors.New("fake-update-err")
+				})
+
+				It("returns error", func() {
+					err := vm.ConfigureNetworks(networks)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-update-err"))
+				})
+			})
+		})
+
+		Context("when a network is on a VLAN the VM is not on", func() {
+			BeforeEach(func() {
+				networks["fake-private-net"] = Network{
+					Type:            "dynamic",
+					CloudProperties: map[string]interface{}{"vlanId": 123456},
+				}
+
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getNetworkComponents.json")
+			})
+
+			It("returns NotSupportedError without updating the agent env", func() {
+				err := vm.ConfigureNetworks(networks)
 				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(NotSupportedError{}))
+				Expect(err.(NotSupportedError).Type()).To(Equal("Bosh::Clouds::NotSupported"))
+
+				Expect(agentEnvService.FetchCalled).To(BeFalse())
 			})
 		})
 	})
 
-	Describe("ConfigureNetworks", func() {
+	Describe("AttachDisk", func() {
 		var (
-			networks Networks
+			disk *fakedisk.FakeDisk
 		)
 
 		BeforeEach(func() {
-			networks = Networks{}
-			vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, logger)
+			disk = fakedisk.NewFakeDisk(1234)
+			vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
 		})
 
-		It("returns NotSupportedError", func() {
-			err := vm.ConfigureNetworks(networks)
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(Equal("Not supported"))
-			Expect(err.(NotSupportedError).Type()).To(Equal("Bosh::Clouds::NotSupported"))
+		Context("when the iSCSI volume is attached", func() {
+			BeforeEach(func() {
+				virtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+				Expect(err).ToNot(HaveOccurred())
+
+				softLayerClient.SoftLayerServices["SoftLayer_Virtual_Guest"] = attachingVirtualGuestService{virtualGuestService, "sdc"}
+				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json"})
+
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb")
+			})
+
+			It("records the device path of the disk in the agent env", func() {
+				err := vm.AttachDisk(disk)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(
+					AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb").AttachPersistentDisk("1234", "/dev/sdc"),
+				))
+			})
 		})
-	})
 
-	Describe("AttachDisk", func() {
-		//TODO: when disk support added to softlayer-go and to CPI
+		Context("when attaching the iSCSI volume fails", func() {
+			BeforeEach(func() {
+				softLayerClient.DoRawHttpRequestError = errors.New("fake-attach-err")
+			})
+
+			It("returns error and does not update the agent env", func() {
+				err := vm.AttachDisk(disk)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-attach-err"))
+
+				Expect(agentEnvService.FetchCalled).To(BeFalse())
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}))
+			})
+		})
 	})
 
 	Describe("DetachDisk", func() {
-		//TODO: when disk support added to softlayer-go and to CPI
+		var (
+			disk *fakedisk.FakeDisk
+		)
+
+		BeforeEach(func() {
+			disk = fakedisk.NewFakeDisk(1234)
+			vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
+		})
+
+		Context("when neither the agent env nor SoftLayer lists the disk", func() {
+			BeforeEach(func() {
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb")
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getEmptyAllowedNetworkStorage.json")
+			})
+
+			It("returns DiskNotAttachedError", func() {
+				err := vm.DetachDisk(disk)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(Equal(DiskNotAttachedError{}))
+				Expect(err.(DiskNotAttachedError).Type()).To(Equal("Bosh::Clouds::DiskNotAttached"))
+			})
+		})
+
+		Context("when the iSCSI volume is authorized to the VM", func() {
+			var (
+				virtualGuestService *detachingVirtualGuestService
+			)
+
+			BeforeEach(func() {
+				slVirtualGuestService, err := softLayerClient.GetSoftLayer_Virtual_Guest_Service()
+				Expect(err).ToNot(HaveOccurred())
+
+				virtualGuestService = &detachingVirtualGuestService{SoftLayer_Virtual_Guest_Service: slVirtualGuestService}
+				softLayerClient.SoftLayerServices["SoftLayer_Virtual_Guest"] = virtualGuestService
+
+				softLayerClient.DoRawHttpRequestResponse = nil
+				common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, []string{
+					"SoftLayer_Virtual_Guest_Service_getAllowedNetworkStorage.json",
+					"SoftLayer_Virtual_Guest_Service_getActiveTransactions.json",
+				})
+			})
+
+			It("detaches the volume and removes the disk from the agent env", func() {
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb").AttachPersistentDisk("1234", "/dev/sdc")
+
+				err := vm.DetachDisk(disk)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(virtualGuestService.DetachedVolumeIds).To(Equal([]int{1234}))
+
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb")))
+			})
+
+			It("detaches the volume even though the agent env does not list the disk", func() {
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb")
+
+				err := vm.DetachDisk(disk)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(virtualGuestService.DetachedVolumeIds).To(Equal([]int{1234}))
+
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}))
+			})
+		})
+
+		Context("when only the agent env lists the disk", func() {
+			BeforeEach(func() {
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb").AttachPersistentDisk("1234", "/dev/sdc")
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getEmptyAllowedNetworkStorage.json")
+			})
+
+			It("removes the disk from the agent env without detaching any volume", func() {
+				err := vm.DetachDisk(disk)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}.AttachPersistentDisk("5678", "/dev/sdb")))
+			})
+		})
+
+		Context("when fetching the agent env fails", func() {
+			BeforeEach(func() {
+				agentEnvService.FetchErr = errors.New("fake-fetch-err")
+			})
+
+			It("returns error", func() {
+				err := vm.DetachDisk(disk)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-fetch-err"))
+			})
+		})
+
+		Context("when detaching the iSCSI volume fails", func() {
+			BeforeEach(func() {
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("1234", "/dev/sdb")
+				softLayerClient.DoRawHttpRequestError = errors.New("fake-detach-err")
+			})
+
+			It("returns error and does not update the agent env", func() {
+				err := vm.DetachDisk(disk)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-detach-err"))
+
+				Expect(agentEnvService.UpdateAgentEnv).To(Equal(AgentEnv{}))
+			})
+		})
+	})
+
+	Describe("GetDisks", func() {
+		BeforeEach(func() {
+			vm = NewSoftLayerVM(1234567, softLayerClient, agentEnvService, waitOptions, logger)
+		})
+
+		Context("when iSCSI volumes are authorized to the VM", func() {
+			BeforeEach(func() {
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getAllowedNetworkStorage.json")
+				agentEnvService.FetchAgentEnv = AgentEnv{}.AttachPersistentDisk("1234", "/dev/sdb")
+			})
+
+			It("returns the IDs of the volumes known to the agent env", func() {
+				diskIDs, err := vm.GetDisks()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskIDs).To(Equal([]int{1234}))
+			})
+
+			Context("when fetching the agent env fails", func() {
+				BeforeEach(func() {
+					agentEnvService.FetchErr = errors.New("fake-fetch-err")
+				})
+
+				It("returns error", func() {
+					_, err := vm.GetDisks()
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("fake-fetch-err"))
+				})
+			})
+		})
+
+		Context("when no volumes are authorized to the VM", func() {
+			BeforeEach(func() {
+				common.SetTestFixtureForFakeSoftLayerClient(softLayerClient, "SoftLayer_Virtual_Guest_Service_getEmptyAllowedNetworkStorage.json")
+			})
+
+			It("returns an empty list without fetching the agent env", func() {
+				diskIDs, err := vm.GetDisks()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diskIDs).To(BeEmpty())
+				Expect(diskIDs).ToNot(BeNil())
+
+				Expect(agentEnvService.FetchCalled).To(BeFalse())
+			})
+		})
+
+		Context("when getting the authorized volumes fails", func() {
+			BeforeEach(func() {
+				softLayerClient.DoRawHttpRequestError = errors.New("fake-get-err")
+			})
+
+			It("returns error", func() {
+				_, err := vm.GetDisks()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-get-err"))
+			})
+		})
 	})
 })
+
+// attachingVirtualGuestService stands in for the iSCSI attachment, which the
+// SoftLayer service otherwise performs over SSH on the VM
+type attachingVirtualGuestService struct {
+	sl.SoftLayer_Virtual_Guest_Service
+
+	deviceName string
+}
+
+func (s attachingVirtualGuestService) AttachIscsiVolume(instanceId int, volumeId int) (string, error) {
+	return s.deviceName, nil
+}
+
+// detachingVirtualGuestService stands in for the iSCSI detachment, which the
+// SoftLayer service otherwise performs over SSH on the VM
+type detachingVirtualGuestService struct {
+	sl.SoftLayer_Virtual_Guest_Service
+
+	DetachedVolumeIds []int
+}
+
+func (s *detachingVirtualGuestService) DetachIscsiVolume(instanceId int, volumeId int) error {
+	s.DetachedVolumeIds = append(s.DetachedVolumeIds, volumeId)
+	return nil
+}
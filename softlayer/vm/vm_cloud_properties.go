@@ -0,0 +1,308 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	bosherr "github.com/cloudfoundry/bosh-agent/errors"
+
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+	sl "github.com/maximilien/softlayer-go/softlayer"
+
+	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+)
+
+type createObjectOptions struct {
+	Processors []struct {
+		Template struct {
+			StartCpus int `json:"startCpus"`
+		} `json:"template"`
+	} `json:"processors"`
+
+	Memory []struct {
+		Template struct {
+			MaxMemory int `json:"maxMemory"`
+		} `json:"template"`
+	} `json:"memory"`
+
+	BlockDevices []struct {
+		Template struct {
+			BlockDevices  []sldatatypes.BlockDevice `json:"blockDevices"`
+			LocalDiskFlag bool                      `json:"localDiskFlag"`
+		} `json:"template"`
+	} `json:"blockDevices"`
+}
+
+// Validate checks the cloud properties against what SoftLayer offers to the
+// account before anything is ordered. It returns an
+// InvalidCloudPropertiesError listing every problem found, or any error
+// raised while asking SoftLayer.
+func (p VMCloudProperties) Validate(softLayerClient sl.Client) error {
+	problems := p.requiredValueProblems()
+
+	options, err := getCreateObjectOptions(softLayerClient)
+	if err != nil {
+		return bosherr.WrapError(err, "Getting VirtualGuest create options")
+	}
+
+	if p.StartCpus > 0 && !containsInt(options.startCpus(), p.StartCpus) {
+		problems = append(problems, fmt.Sprintf("StartCpus %d is not offered, available values are %v", p.StartCpus, options.startCpus()))
+	}
+
+	if p.MaxMemory > 0 && !containsInt(options.maxMemory(), p.MaxMemory) {
+		problems = append(problems, fmt.Sprintf("MaxMemory %dMB is not offered, available values are %v", p.MaxMemory, options.maxMemory()))
+	}
+
+	problems = append(problems, blockDeviceProblems(blockDevicesFor(p), p.UsesLocalDisks(), options)...)
+
+	if p.Datacenter.Name != "" {
+		datacenterNames, err := getDatacenterNames(softLayerClient)
+		if err != nil {
+			return bosherr.WrapError(err, "Getting datacenters with subnet allocations")
+		}
+
+		if !containsString(datacenterNames, p.Datacenter.Name) {
+			problems = append(problems, fmt.Sprintf("Datacenter '%s' has no subnet allocations for the account, available datacenters are %v", p.Datacenter.Name, datacenterNames))
+		}
+	}
+
+	if len(p.SshKeys) > 0 {
+		sshKeyIds, err := getSshKeyIds(softLayerClient)
+		if err != nil {
+			return bosherr.WrapError(err, "Getting SSH keys of the account")
+		}
+
+		for _, sshKey := range p.SshKeys {
+			if !containsInt(sshKeyIds, sshKey.Id) {
+				problems = append(problems, fmt.Sprintf("SSH key `%d` does not belong to the account", sshKey.Id))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return bslcommon.InvalidCloudPropertiesError{Problems: problems}
+	}
+
+	return nil
+}
+
+// MergeDefaults returns the cloud properties with every unset value taken
+// from defaults
+func (p VMCloudProperties) MergeDefaults(defaults VMCloudProperties) VMCloudProperties {
+	merged := p
+
+	if merged.Domain == "" {
+		merged.Domain = defaults.Domain
+	}
+
+	if merged.StartCpus == 0 {
+		merged.StartCpus = defaults.StartCpus
+	}
+
+	if merged.MaxMemory == 0 {
+		merged.MaxMemory = defaults.MaxMemory
+	}
+
+	if merged.Datacenter.Name == "" {
+		merged.Datacenter = defaults.Datacenter
+	}
+
+	if merged.BlockDeviceTemplateGroup.GlobalIdentifier == "" {
+		merged.BlockDeviceTemplateGroup = defaults.BlockDeviceTemplateGroup
+	}
+
+	if len(merged.SshKeys) == 0 {
+		merged.SshKeys = defaults.SshKeys
+	}
+
+	if merged.RootDiskSize == 0 {
+		merged.RootDiskSize = defaults.RootDiskSize
+	}
+
+	if merged.EphemeralDiskSize == 0 {
+		merged.EphemeralDiskSize = defaults.EphemeralDiskSize
+	}
+
+	if merged.LocalDiskFlag == nil {
+		merged.LocalDiskFlag = defaults.LocalDiskFlag
+	}
+
+	if merged.Os == "" {
+		merged.Os = defaults.Os
+	}
+
+	if merged.BareMetal == nil {
+		merged.BareMetal = defaults.BareMetal
+	}
+
+	return merged
+}
+
+// BareMetalCloudProperties maps the cloud properties onto the bare-metal
+// server to order. MaxMemory is given in MB while bare-metal servers are
+// sized in GB, and the root disk is the only disk.
+func (p VMCloudProperties) BareMetalCloudProperties(hostname string) bslcbm.BareMetalCloudProperties {
+	return bslcbm.BareMetalCloudProperties{
+		Memory:     p.MaxMemory / 1024,
+		Processor:  p.StartCpus,
+		DiskSize:   p.RootDiskSize,
+		Hostname:   hostname,
+		Domain:     p.Domain,
+		Os:         p.Os,
+		Datacenter: p.Datacenter.Name,
+	}
+}
+
+// UsesBareMetal tells whether a bare-metal server is to be ordered, which it
+// is only when BareMetal is set to true
+func (p VMCloudProperties) UsesBareMetal() bool {
+	return p.BareMetal != nil && *p.BareMetal
+}
+
+// validateBareMetal returns an InvalidCloudPropertiesError when the cloud
+// properties cannot be mapped onto a bare-metal server
+func (p VMCloudProperties) validateBareMetal() error {
+	if p.MaxMemory%1024 != 0 {
+		return bslcommon.InvalidCloudPropertiesError{Problems: []string{
+			fmt.Sprintf("MaxMemory %dMB is not a whole number of GB, which bare-metal servers are sized in", p.MaxMemory),
+		}}
+	}
+
+	return nil
+}
+
+// UsesLocalDisks tells whether the block devices are local rather than SAN
+// disks, which they are unless LocalDiskFlag is set to false
+func (p VMCloudProperties) UsesLocalDisks() bool {
+	return p.LocalDiskFlag == nil || *p.LocalDiskFlag
+}
+
+func (p VMCloudProperties) requiredValueProblems() []string {
+	problems, requiredTemplate := []string{}, "%s is required and cannot be empty"
+
+	if p.Domain == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "Domain for the computing instance"))
+	}
+
+	if p.StartCpus <= 0 {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "StartCpus: the number of CPU cores to allocate"))
+	}
+
+	if p.MaxMemory <= 0 {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "MaxMemory: the amount of memory to allocate in megabytes"))
+	}
+
+	if p.Datacenter.Name == "" {
+		problems = append(problems, fmt.Sprintf(requiredTemplate, "Datacenter.Name: specifies which datacenter the instance is to be provisioned in"))
+	}
+
+	if p.RootDiskSize < 0 {
+		problems = append(problems, fmt.Sprintf("RootDiskSize must be a positive number, it is set to be %dGB", p.RootDiskSize))
+	}
+
+	if p.EphemeralDiskSize < 0 {
+		problems = append(problems, fmt.Sprintf("EphemeralDiskSize must be a positive number, it is set to be %dGB", p.EphemeralDiskSize))
+	}
+
+	return problems
+}
+
+func (o createObjectOptions) startCpus() []int {
+	startCpus := []int{}
+	for _, processor := range o.Processors {
+		startCpus = append(startCpus, processor.Template.StartCpus)
+	}
+	sort.Ints(startCpus)
+
+	return startCpus
+}
+
+func (o createObjectOptions) maxMemory() []int {
+	maxMemory := []int{}
+	for _, memory := range o.Memory {
+		maxMemory = append(maxMemory, memory.Template.MaxMemory)
+	}
+	sort.Ints(maxMemory)
+
+	return maxMemory
+}
+
+func getCreateObjectOptions(softLayerClient sl.Client) (createObjectOptions, error) {
+	response, err := softLayerClient.DoRawHttpRequest("SoftLayer_Virtual_Guest/getCreateObjectOptions.json", "GET", new(bytes.Buffer))
+	if err != nil {
+		return createObjectOptions{}, err
+	}
+
+	options := createObjectOptions{}
+	err = json.Unmarshal(response, &options)
+	if err != nil {
+		return createObjectOptions{}, bosherr.WrapError(err, "Unmarshalling VirtualGuest create options")
+	}
+
+	return options, nil
+}
+
+// getDatacenterNames asks SoftLayer directly since softlayer-go does not
+// implement SoftLayer_Account#getDatacentersWithSubnetAllocations
+func getDatacenterNames(softLayerClient sl.Client) ([]string, error) {
+	response, err := softLayerClient.DoRawHttpRequest("SoftLayer_Account/getDatacentersWithSubnetAllocations.json", "GET", new(bytes.Buffer))
+	if err != nil {
+		return []string{}, err
+	}
+
+	locations := []sldatatypes.SoftLayer_Location{}
+	err = json.Unmarshal(response, &locations)
+	if err != nil {
+		return []string{}, bosherr.WrapError(err, "Unmarshalling datacenters")
+	}
+
+	names := []string{}
+	for _, location := range locations {
+		names = append(names, location.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func getSshKeyIds(softLayerClient sl.Client) ([]int, error) {
+	accountService, err := softLayerClient.GetSoftLayer_Account_Service()
+	if err != nil {
+		return []int{}, bosherr.WrapError(err, "Creating AccountService from SoftLayer client")
+	}
+
+	sshKeys, err := accountService.GetSshKeys()
+	if err != nil {
+		return []int{}, err
+	}
+
+	ids := []int{}
+	for _, sshKey := range sshKeys {
+		ids = append(ids, sshKey.Id)
+	}
+
+	return ids, nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package vm_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maximilien/bosh-softlayer-cpi/softlayer/vm"
+
+	common "github.com/maximilien/bosh-softlayer-cpi/common"
+	bslcbm "github.com/maximilien/bosh-softlayer-cpi/softlayer/baremetal"
+	bslcommon "github.com/maximilien/bosh-softlayer-cpi/softlayer/common"
+
+	fakeslclient "github.com/maximilien/softlayer-go/client/fakes"
+	sldatatypes "github.com/maximilien/softlayer-go/data_types"
+)
+
+var _ = Describe("VMCloudProperties", func() {
+	var (
+		softLayerClient *fakeslclient.FakeSoftLayerClient
+		cloudProps      VMCloudProperties
+	)
+
+	BeforeEach(func() {
+		softLayerClient = fakeslclient.NewFakeSoftLayerClient("fake-username", "fake-api-key")
+
+		cloudProps = VMCloudProperties{
+			Domain:            "fake-domain.com",
+			StartCpus:         2,
+			MaxMemory:         2048,
+			Datacenter:        sldatatypes.Datacenter{Name: "ams01"},
+			SshKeys:           []sldatatypes.SshKey{sldatatypes.SshKey{Id: 74826}},
+			RootDiskSize:      25,
+			EphemeralDiskSize: 100,
+		}
+
+		fileNames := []string{
+			"SoftLayer_Virtual_Guest_Service_getCreateObjectOptions.json",
+			"SoftLayer_Account_Service_getDatacentersWithSubnetAllocations.json",
+			"SoftLayer_Account_Service_getSshKeys.json",
+		}
+		common.SetTestFixturesForFakeSoftLayerClient(softLayerClient, fileNames)
+	})
+
+	Describe("Validate", func() {
+		It("succeeds when SoftLayer offers everything requested", func() {
+			err := cloudProps.Validate(softLayerClient)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an bslcommon.InvalidCloudPropertiesError listing every problem", func() {
+			cloudProps.StartCpus = 3
+			cloudProps.MaxMemory = 3000
+			cloudProps.Datacenter.Name = "fake-datacenter"
+			cloudProps.SshKeys = []sldatatypes.SshKey{sldatatypes.SshKey{Id: 1234}}
+
+			err := cloudProps.Validate(softLayerClient)
+			Expect(err).To(BeAssignableToTypeOf(bslcommon.InvalidCloudPropertiesError{}))
+			Expect(err.(bslcommon.InvalidCloudPropertiesError).Problems).To(Equal([]string{
+				"StartCpus 3 is not offered, available values are [1 2 4 8 12 16]",
+				"MaxMemory 3000MB is not offered, available values are [1024 2048 4096 8192 16384]",
+				"Datacenter 'fake-datacenter' has no subnet allocations for the account, available datacenters are [ams01 dal05 dal09]",
+				"SSH key `1234` does not belong to the account",
+			}))
+		})
+
+		It("reports missing required values", func() {
+			cloudProps = VMCloudProperties{}
+
+			err := cloudProps.Validate(softLayerClient)
+			Expect(err).To(BeAssignableToTypeOf(bslcommon.InvalidCloudPropertiesError{}))
+			Expect(err.Error()).To(ContainSubstring("Domain for the computing instance is required"))
+			Expect(err.Error()).To(ContainSubstring("StartCpus: the number of CPU cores to allocate is required"))
+			Expect(err.Error()).To(ContainSubstring("MaxMemory: the amount of memory to allocate in megabytes is required"))
+			Expect(err.Error()).To(ContainSubstring("Datacenter.Name: specifies which datacenter the instance is to be provisioned in is required"))
+		})
+
+		It("returns error when the create options cannot be fetched", func() {
+			softLayerClient.DoRawHttpRequestError = errors.New("fake-request-err")
+
+			err := cloudProps.Validate(softLayerClient)
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(BeAssignableToTypeOf(bslcommon.InvalidCloudPropertiesError{}))
+			Expect(err.Error()).To(ContainSubstring("Getting VirtualGuest create options"))
+		})
+	})
+
+	Describe("MergeDefaults", func() {
+		var (
+			localDisks, sanDisks bool
+		)
+
+		BeforeEach(func() {
+			localDisks, sanDisks = true, false
+		})
+
+		It("takes localDiskFlag from the defaults when it is not set", func() {
+			merged := VMCloudProperties{}.MergeDefaults(VMCloudProperties{LocalDiskFlag: &sanDisks})
+			Expect(merged.UsesLocalDisks()).To(BeFalse())
+		})
+
+		It("keeps a localDiskFlag explicitly set to false over defaults set to true", func() {
+			var cloudProps VMCloudProperties
+			Expect(json.Unmarshal([]byte(`{"localDiskFlag": false}`), &cloudProps)).To(Succeed())
+
+			merged := cloudProps.MergeDefaults(VMCloudProperties{LocalDiskFlag: &localDisks})
+			Expect(merged.UsesLocalDisks()).To(BeFalse())
+		})
+
+		It("keeps bare_metal set to false over defaults set to true", func() {
+			var cloudProps VMCloudProperties
+			Expect(json.Unmarshal([]byte(`{"bare_metal": false}`), &cloudProps)).To(Succeed())
+
+			bareMetal := true
+			merged := cloudProps.MergeDefaults(VMCloudProperties{BareMetal: &bareMetal})
+			Expect(merged.UsesBareMetal()).To(BeFalse())
+
+			merged = VMCloudProperties{}.MergeDefaults(VMCloudProperties{BareMetal: &bareMetal})
+			Expect(merged.UsesBareMetal()).To(BeTrue())
+		})
+	})
+
+	Describe("BareMetalCloudProperties", func() {
+		It("maps the cloud properties onto the bare-metal server to order", func() {
+			cloudProps.Os = "UBUNTU_LATEST"
+
+			Expect(cloudProps.BareMetalCloudProperties("fake-hostname")).To(Equal(bslcbm.BareMetalCloudProperties{
+				Memory:     2,
+				Processor:  2,
+				DiskSize:   25,
+				Hostname:   "fake-hostname",
+				Domain:     "fake-domain.com",
+				Os:         "UBUNTU_LATEST",
+				Datacenter: "ams01",
+			}))
+		})
+	})
+})
@@ -0,0 +1,10 @@
+package fakes
+
+type FakeIPResolver struct {
+	LocalIPsIPs []string
+	LocalIPsErr error
+}
+
+func (r FakeIPResolver) LocalIPs() ([]string, error) {
+	return r.LocalIPsIPs, r.LocalIPsErr
+}
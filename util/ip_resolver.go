@@ -0,0 +1,31 @@
+package util
+
+import (
+	"net"
+)
+
+type IPResolver interface {
+	// LocalIPs returns the non loopback IPv4 addresses of the local machine
+	LocalIPs() ([]string, error)
+}
+
+type InterfaceIPResolver struct{}
+
+func (r InterfaceIPResolver) LocalIPs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return []string{}, err
+	}
+
+	ips := []string{}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+
+		ips = append(ips, ipNet.IP.String())
+	}
+
+	return ips, nil
+}